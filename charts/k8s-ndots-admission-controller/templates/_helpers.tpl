@@ -98,3 +98,17 @@ Create the issuer name
 {{- end }}
 {{- end }}
 
+
+{{/*
+Owner kinds as OWNER_RESOURCES entries: group/version/Kind=resource, or
+version/Kind=resource for the core group
+*/}}
+{{- define "k8s-ndots-admission-controller.ownerResources" -}}
+{{- $entries := list }}
+{{- range . }}
+{{- $gv := .version }}
+{{- if .group }}{{ $gv = printf "%s/%s" .group .version }}{{ end }}
+{{- $entries = append $entries (printf "%s/%s=%s" $gv .kind .resource) }}
+{{- end }}
+{{- join "," $entries }}
+{{- end }}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/metrics"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/owner"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/report"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/tenant"
+)
+
+// eventComponent is the source component of Events recorded by the webhook.
+const eventComponent = "ndots-webhook"
+
+// kubeClients lazily creates the in-cluster API clients shared by the
+// optional components, so the webhook needs no API access when they are off.
+type kubeClients struct {
+	restCfg   *rest.Config
+	clientset kubernetes.Interface
+	recorder  record.EventRecorder
+	pods      informers.SharedInformerFactory
+}
+
+func (k *kubeClients) config() (*rest.Config, error) {
+	if k.restCfg == nil {
+		restCfg, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+		k.restCfg = restCfg
+	}
+	return k.restCfg, nil
+}
+
+func (k *kubeClients) kubernetes() (kubernetes.Interface, error) {
+	if k.clientset == nil {
+		restCfg, err := k.config()
+		if err != nil {
+			return nil, err
+		}
+		clientset, err := kubernetes.NewForConfig(restCfg)
+		if err != nil {
+			return nil, err
+		}
+		k.clientset = clientset
+	}
+	return k.clientset, nil
+}
+
+// eventRecorder returns a recorder that writes Events asynchronously; the
+// broadcaster is shut down when ctx is done.
+func (k *kubeClients) eventRecorder(ctx context.Context) (record.EventRecorder, error) {
+	if k.recorder == nil {
+		clientset, err := k.kubernetes()
+		if err != nil {
+			return nil, err
+		}
+		broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+		k.recorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+	}
+	return k.recorder, nil
+}
+
+// podInformers returns the informer factory for the cluster-wide pod cache
+// shared by the reporter and the compliance collector. Every user starts it
+// after registering its informers.
+func (k *kubeClients) podInformers() (informers.SharedInformerFactory, error) {
+	if k.pods == nil {
+		clientset, err := k.kubernetes()
+		if err != nil {
+			return nil, err
+		}
+		k.pods = informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+			informers.WithTransform(stripManagedFields),
+		)
+	}
+	return k.pods, nil
+}
+
+// startOwnerResolver starts the metadata informers used to inherit the
+// opt-in/opt-out key from owning workloads, of the configured kinds or the
+// built-in ones. Until the caches have synced lookups find no owners.
+func startOwnerResolver(ctx context.Context, cfg *config.Config, clients *kubeClients, logger *slog.Logger) (*owner.Resolver, error) {
+	restCfg, err := clients.config()
+	if err != nil {
+		return nil, err
+	}
+	client, err := metadata.NewForConfig(restCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := owner.DefaultResources
+	if len(cfg.OwnerResources) > 0 {
+		if resources, err = owner.ParseResources(cfg.OwnerResources); err != nil {
+			return nil, err
+		}
+	}
+
+	factory := metadatainformer.NewSharedInformerFactory(client, 0)
+	resolver := owner.NewResolver(factory, resources, cfg.OwnerLookupDepth)
+	factory.Start(ctx.Done())
+
+	waitForSync(ctx, cfg.CacheSyncTimeout, resolver.HasSynced, "owner", logger)
+	return resolver, nil
+}
+
+// startTenantPolicies starts the ConfigMap informer backing per-namespace
+// tenant policies. It only watches ConfigMaps with the configured name.
+// Until the cache has synced no tenant policy applies.
+func startTenantPolicies(ctx context.Context, cfg *config.Config, clients *kubeClients, logger *slog.Logger) (*tenant.Store, error) {
+	clientset, err := clients.kubernetes()
+	if err != nil {
+		return nil, err
+	}
+	recorder, err := clients.eventRecorder(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", cfg.TenantPolicyConfigMap).String()
+		}),
+	)
+	limits := tenant.Limits{
+		AllowedModes: cfg.TenantAllowedModes,
+		NdotsMin:     cfg.TenantNdotsMin,
+		NdotsMax:     cfg.TenantNdotsMax,
+	}
+	store, err := tenant.NewStore(factory, cfg.TenantPolicyConfigMap, limits, recorder, logger)
+	if err != nil {
+		return nil, err
+	}
+	factory.Start(ctx.Done())
+
+	waitForSync(ctx, cfg.CacheSyncTimeout, store.HasSynced, "tenant policy", logger)
+	return store, nil
+}
+
+// startPolicyReports starts the pod informer and the reporter that keeps
+// PolicyReports in line with it, using checker for the verdicts.
+func startPolicyReports(ctx context.Context, cfg *config.Config, clients *kubeClients, checker report.Checker, logger *slog.Logger) error {
+	factory, err := clients.podInformers()
+	if err != nil {
+		return err
+	}
+	restCfg, err := clients.config()
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	reporter, err := report.NewReporter(factory, dynamicClient, checker, cfg.PolicyReportWriteQPS, cfg.PolicyReportResync, logger.With("component", "policyreport"))
+	if err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	go reporter.Run(ctx)
+	return nil
+}
+
+// namespaceLister adapts a Namespace lister to admission.NamespaceLookup.
+type namespaceLister struct {
+	lister corelisters.NamespaceLister
+}
+
+func (l namespaceLister) Namespace(name string) (*corev1.Namespace, bool) {
+	ns, err := l.lister.Get(name)
+	return ns, err == nil
+}
+
+// startNamespaceLookup starts the Namespace informer that provides
+// namespaceObject to the policy expressions. Until the cache has synced
+// namespaceObject is null.
+func startNamespaceLookup(ctx context.Context, cfg *config.Config, clients *kubeClients, logger *slog.Logger) (admission.NamespaceLookup, error) {
+	clientset, err := clients.kubernetes()
+	if err != nil {
+		return nil, err
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0,
+		informers.WithTransform(stripManagedFields),
+	)
+	namespaces := factory.Core().V1().Namespaces()
+	lister := namespaceLister{lister: namespaces.Lister()}
+	synced := namespaces.Informer().HasSynced
+	factory.Start(ctx.Done())
+
+	waitForSync(ctx, cfg.CacheSyncTimeout, synced, "namespace", logger)
+	return lister, nil
+}
+
+// startComplianceMetrics starts the pod informer and returns a collector of
+// running pod compliance, evaluated with checker.
+func startComplianceMetrics(ctx context.Context, clients *kubeClients, checker metrics.PodChecker) (*metrics.ComplianceCollector, error) {
+	factory, err := clients.podInformers()
+	if err != nil {
+		return nil, err
+	}
+	pods := factory.Core().V1().Pods()
+	collector := metrics.NewComplianceCollector(pods.Lister(), pods.Informer().HasSynced, checker)
+	factory.Start(ctx.Done())
+	return collector, nil
+}
+
+// stripManagedFields drops managed fields from cached objects; they are
+// never read and make up a large part of each pod.
+func stripManagedFields(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// waitForSync waits at most timeout for synced, logging a warning if the
+// caches are still syncing afterwards. It never fails startup.
+func waitForSync(ctx context.Context, timeout time.Duration, synced cache.InformerSynced, what string, logger *slog.Logger) {
+	syncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), synced) {
+		logger.Warn(what+" caches not synced yet, continuing without them until they are",
+			"timeout", timeout,
+		)
+	}
+}
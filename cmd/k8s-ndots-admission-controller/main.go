@@ -13,11 +13,15 @@ import (
 
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/events"
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/logging"
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/metrics"
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/server"
 )
 
+// eventQueueSize bounds the pod events waiting to be sent to the API server.
+const eventQueueSize = 1024
+
 func main() {
 	// 1. Load configuration
 	cfg, err := config.Load()
@@ -31,13 +35,114 @@ func main() {
 	slog.SetDefault(logger)
 	logger.Info("Configuration applied", "config", cfg)
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// 3. Setup metrics
 	reg := prometheus.NewRegistry()
-	metricsRecorder := metrics.NewRecorder(reg)
+	var metricsOpts []metrics.Option
+	if cfg.MetricsWorkloadLabel {
+		metricsOpts = append(metricsOpts, metrics.WithWorkloadLabel())
+	}
+	metricsRecorder := metrics.NewRecorder(reg, metricsOpts...)
 
 	// 4. Initialize components
 	mutator := admission.NewMutator(cfg, logger)
-	handler := admission.NewHandlerWithMetrics(mutator, logger, metricsRecorder)
+	candidateCfg, err := config.LoadCandidate(cfg)
+	if err != nil {
+		logger.Error("failed to load candidate policy", "error", err)
+		os.Exit(1)
+	}
+	// The shadow candidate shares the owner, tenant policy and namespace
+	// caches of the active mutator, so that both decide on the same state.
+	mutators := []*admission.Mutator{mutator}
+	var candidate *admission.Mutator
+	shadowLogger := logger.With("policy", "candidate")
+	if candidateCfg != nil {
+		logger.Info("evaluating candidate policy in shadow", "candidate", candidateCfg)
+		candidate = admission.NewMutator(candidateCfg, shadowLogger)
+		mutators = append(mutators, candidate)
+	}
+	var decisionCache *admission.DecisionCache
+	if cfg.DecisionCacheSize > 0 {
+		decisionCache = admission.NewDecisionCache(cfg.DecisionCacheSize)
+	}
+	clients := &kubeClients{}
+	if cfg.InheritFromOwner {
+		resolver, err := startOwnerResolver(ctx, cfg, clients, logger)
+		if err != nil {
+			logger.Error("failed to start owner resolver", "error", err)
+			os.Exit(1)
+		}
+		for _, m := range mutators {
+			m.SetOwnerLookup(resolver)
+		}
+	}
+	if cfg.TenantPolicyEnabled {
+		store, err := startTenantPolicies(ctx, cfg, clients, logger)
+		if err != nil {
+			logger.Error("failed to start tenant policies", "error", err)
+			os.Exit(1)
+		}
+		for _, m := range mutators {
+			m.SetPolicySource(store)
+		}
+		if decisionCache != nil {
+			store.OnChange(decisionCache.Invalidate)
+		}
+	}
+	if usesExpressions(cfg) || (candidateCfg != nil && usesExpressions(candidateCfg)) {
+		namespaces, err := startNamespaceLookup(ctx, cfg, clients, logger)
+		if err != nil {
+			logger.Error("failed to start namespace lookup", "error", err)
+			os.Exit(1)
+		}
+		for _, m := range mutators {
+			m.SetNamespaceLookup(namespaces)
+		}
+		mutator.SetExpressionMetrics(metricsRecorder)
+	}
+	if cfg.PolicyReportEnabled {
+		if err := startPolicyReports(ctx, cfg, clients, mutator, logger); err != nil {
+			logger.Error("failed to start policy reports", "error", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.ComplianceMetricsEnabled {
+		collector, err := startComplianceMetrics(ctx, clients, mutator)
+		if err != nil {
+			logger.Error("failed to start compliance metrics", "error", err)
+			os.Exit(1)
+		}
+		reg.MustRegister(collector)
+	}
+	handler := admission.NewHandlerWithMetrics(newChain(cfg, mutator, logger), logger, metricsRecorder)
+	handler.SetTestOperations(cfg.PatchTestOperations)
+	handler.SetFailurePolicy(failurePolicy(cfg))
+	if decisionCache != nil {
+		handler.SetDecisionCache(decisionCache)
+	}
+	if candidate != nil {
+		handler.SetShadow(admission.NewShadow(newChain(candidateCfg, candidate, shadowLogger), cfg.ShadowLogEvery, shadowLogger))
+	}
+	if cfg.EventsEnabled {
+		recorder, err := clients.eventRecorder(ctx)
+		if err != nil {
+			logger.Error("failed to create event recorder", "error", err)
+			os.Exit(1)
+		}
+		podEvents := events.NewRecorder(recorder, eventQueueSize, logger)
+		go podEvents.Run(ctx)
+		handler.SetEvents(podEvents)
+	}
+	var decisions *admission.DecisionRing
+	if cfg.DebugToken != "" {
+		decisions = admission.NewDecisionRing(cfg.DecisionBufferSize)
+		handler.SetDecisionRing(decisions)
+	}
+	if !cfg.AuditUntil.IsZero() || !cfg.WarnUntil.IsZero() {
+		handler.SetSchedule(admission.NewSchedule(cfg.AuditUntil, cfg.WarnUntil, time.Now))
+	}
 
 	// 5. Create server config
 	srvCfg := server.Config{
@@ -52,6 +157,14 @@ func main() {
 
 	// Register application routes
 	mux.HandleFunc("/mutate", handler.HandleMutate)
+	if cfg.ExplainEnabled {
+		explain := admission.NewExplainHandler(mutator)
+		explain.SetFailurePolicy(failurePolicy(cfg))
+		mux.Handle("/explain", explain)
+	}
+	if decisions != nil {
+		mux.Handle("/debug/decisions", server.RequireBearerToken(cfg.DebugToken, decisions))
+	}
 
 	// Server setup
 	srv, err := server.New(srvCfg, mux)
@@ -83,9 +196,6 @@ func main() {
 	}()
 
 	// Wait for interrupt signal
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
-
 	<-ctx.Done()
 	logger.Info("shutting down servers...")
 
@@ -103,3 +213,30 @@ func main() {
 
 	logger.Info("servers stopped")
 }
+
+// newChain returns the chain of mutators cfg enables, starting with ndots.
+func newChain(cfg *config.Config, ndots *admission.Mutator, logger *slog.Logger) *admission.Chain {
+	links := []admission.ChainLink{{Name: "ndots", Mutator: ndots}}
+	if len(cfg.SearchDomains) > 0 {
+		links = append(links, admission.ChainLink{Name: "search", Mutator: admission.NewSearchDomains(cfg, logger)})
+	}
+	return admission.NewChain(logger, links...)
+}
+
+// failurePolicy returns the handler's failure policy configured in cfg.
+func failurePolicy(cfg *config.Config) admission.FailurePolicy {
+	p := admission.FailurePolicy{
+		Default:    admission.FailureMode(cfg.FailureMode),
+		Namespaces: make(map[string]admission.FailureMode, len(cfg.FailureModeNamespaces)),
+	}
+	for ns, mode := range cfg.FailureModeNamespaces {
+		p.Namespaces[ns] = admission.FailureMode(mode)
+	}
+	return p
+}
+
+// usesExpressions reports whether cfg configures a policy expression, which
+// needs the namespace lookup.
+func usesExpressions(cfg *config.Config) bool {
+	return cfg.MutationCondition != "" || cfg.NdotsExpression != ""
+}
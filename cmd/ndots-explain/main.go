@@ -0,0 +1,182 @@
+// Command ndots-explain asks the webhook's /explain endpoint how it would
+// handle a pod and prints the trace.
+//
+//	kubectl get pod web -o yaml | ndots-explain -url https://localhost:8443/explain -insecure
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+)
+
+func main() {
+	endpoint := flag.String("url", "https://localhost:8443/explain", "URL of the webhook's /explain endpoint")
+	file := flag.String("f", "-", "Pod or AdmissionReview manifest, YAML or JSON; - reads stdin")
+	namespace := flag.String("n", "", "namespace for pods that do not set one")
+	caFile := flag.String("ca", "", "CA bundle to verify the webhook certificate")
+	insecure := flag.Bool("insecure", false, "skip verification of the webhook certificate")
+	output := flag.String("o", "text", "output format: text or json")
+	flag.Parse()
+
+	if err := run(*endpoint, *file, *namespace, *caFile, *insecure, *output, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ndots-explain:", err)
+		os.Exit(1)
+	}
+}
+
+func run(endpoint, file, namespace, caFile string, insecure bool, output string, out io.Writer) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	manifest, err := readManifest(file)
+	if err != nil {
+		return err
+	}
+	body, err := yaml.YAMLToJSON(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	client, err := newClient(caFile, insecure)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if namespace != "" {
+		q := u.Query()
+		q.Set("namespace", namespace)
+		u.RawQuery = q.Encode()
+	}
+
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if output == "json" {
+		_, err := out.Write(respBody)
+		return err
+	}
+	var trace admission.Trace
+	if err := json.Unmarshal(respBody, &trace); err != nil {
+		return fmt.Errorf("failed to decode trace: %w", err)
+	}
+	printTrace(out, &trace)
+	return nil
+}
+
+func readManifest(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+func newClient(caFile string, insecure bool) (*http.Client, error) {
+	// insecure is meant for port-forwards to the webhook Service.
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}, nil
+}
+
+func printTrace(out io.Writer, t *admission.Trace) {
+	fmt.Fprintf(out, "Pod:              %s/%s (%s)\n", t.Namespace, t.Name, t.Workload)
+
+	filter := "included"
+	if !t.NamespaceFilter.Mutate {
+		filter = t.NamespaceFilter.Reason
+	}
+	fmt.Fprintf(out, "Namespace filter: %s\n", filter)
+
+	if p := t.NamespacePolicy; p != nil {
+		source := "cluster-wide"
+		if p.Tenant {
+			source = "namespace policy"
+		}
+		fmt.Fprintf(out, "Policy:           mode %s, ndots %d (%s)\n", p.Mode, p.Ndots, source)
+		if p.Exempt {
+			fmt.Fprintln(out, "                  pod matches the namespace policy exemption")
+		}
+	}
+
+	if k := t.Key; k != nil {
+		seen := "not set"
+		if k.Found {
+			seen = fmt.Sprintf("%s=%q", k.FoundKey, k.Value)
+			if k.InheritedFrom != "" {
+				seen += " inherited from " + k.InheritedFrom
+			}
+		}
+		fmt.Fprintf(out, "Key:              %s (%s): %s\n", k.Key, k.Source, seen)
+	}
+
+	dnsConfig := "none"
+	if t.DNSConfig != nil {
+		b, _ := json.Marshal(t.DNSConfig)
+		dnsConfig = string(b)
+	}
+	dnsPolicy := t.DNSPolicy
+	if dnsPolicy == "" {
+		dnsPolicy = "ClusterFirst"
+	}
+	fmt.Fprintf(out, "DNS policy:       %s\n", dnsPolicy)
+	fmt.Fprintf(out, "DNS config:       %s\n", dnsConfig)
+
+	if t.Error != "" {
+		answer := "admitted unchanged"
+		if t.FailureMode == admission.FailureDeny {
+			answer = "rejected"
+		}
+		fmt.Fprintf(out, "Error:            %s (%s)\n", t.Error, answer)
+	} else {
+		fmt.Fprintf(out, "Decision:         %s (%s: %s)\n", t.Decision, t.Reason, t.Rule)
+	}
+	for _, op := range t.Patch {
+		b, _ := json.Marshal(op)
+		fmt.Fprintf(out, "  %s\n", b)
+	}
+
+	fmt.Fprintln(out, "\nEffective resolv.conf:")
+	for _, line := range strings.Split(strings.TrimRight(t.ResolvConf, "\n"), "\n") {
+		fmt.Fprintf(out, "  %s\n", line)
+	}
+}
@@ -1,6 +1,9 @@
 package admission
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 type AnnotationMode string
 
@@ -10,34 +13,178 @@ const (
 	ModeOptOut AnnotationMode = "opt-out"
 )
 
+// AnnotationSource selects where the opt-in/opt-out key is read from.
+type AnnotationSource string
+
+const (
+	FromAnnotation AnnotationSource = "annotation"
+	FromLabel      AnnotationSource = "label"
+	FromEither     AnnotationSource = "either" // label takes precedence
+)
+
 type AnnotationChecker struct {
-	key  string
-	mode AnnotationMode
+	key     string
+	aliases []string
+	mode    AnnotationMode
+	source  AnnotationSource
 }
 
 func NewAnnotationChecker(key string, mode string) *AnnotationChecker {
+	return NewAnnotationCheckerWithSource(key, mode, string(FromAnnotation))
+}
+
+// NewAnnotationCheckerWithSource creates a checker that reads the key from
+// the given source. An empty source means annotations.
+func NewAnnotationCheckerWithSource(key, mode, source string) *AnnotationChecker {
+	src := AnnotationSource(strings.ToLower(source))
+	if src == "" {
+		src = FromAnnotation
+	}
 	return &AnnotationChecker{
-		key:  key,
-		mode: AnnotationMode(strings.ToLower(mode)),
+		key:    key,
+		mode:   AnnotationMode(strings.ToLower(mode)),
+		source: src,
 	}
 }
 
+// WithMode returns a copy of the checker that uses mode.
+func (c *AnnotationChecker) WithMode(mode string) *AnnotationChecker {
+	cp := *c
+	cp.mode = AnnotationMode(strings.ToLower(mode))
+	return &cp
+}
+
+// WithAliases returns a copy of the checker that also accepts the deprecated
+// aliases of its key. The primary key takes precedence over the aliases,
+// which take precedence in the given order.
+func (c *AnnotationChecker) WithAliases(aliases ...string) *AnnotationChecker {
+	cp := *c
+	cp.aliases = aliases
+	return &cp
+}
+
+// Key returns the primary opt-in/opt-out key.
+func (c *AnnotationChecker) Key() string {
+	return c.key
+}
+
+// Mode returns the mutation mode.
+func (c *AnnotationChecker) Mode() AnnotationMode {
+	return c.mode
+}
+
+// Source returns where the key is read from.
+func (c *AnnotationChecker) Source() AnnotationSource {
+	return c.source
+}
+
 // ShouldMutate determines if mutation is required based on annotations.
 func (c *AnnotationChecker) ShouldMutate(annotations map[string]string) bool {
+	return c.ShouldMutateObject(nil, annotations)
+}
+
+// ShouldMutateObject determines if mutation is required based on the
+// object's labels and annotations, read according to the configured source.
+func (c *AnnotationChecker) ShouldMutateObject(labels, annotations map[string]string) bool {
+	mutate, _, _ := c.Evaluate(labels, annotations)
+	return mutate
+}
+
+// Evaluate is ShouldMutateObject with the reason for the result and the
+// key and value it is based on.
+func (c *AnnotationChecker) Evaluate(labels, annotations map[string]string) (bool, ReasonCode, string) {
+	value, key, found := c.lookup(labels, annotations)
+	rule := c.key + " unset"
+	if found {
+		rule = fmt.Sprintf("%s=%q", key, value)
+	}
+
 	switch c.mode {
-	case ModeAlways:
-		return true
 	case ModeOptIn:
-		if annotations == nil {
-			return false
+		if found && value == "true" {
+			return true, ReasonOptedIn, rule
 		}
-		return annotations[c.key] == "true"
+		return false, ReasonNotOptedIn, rule
 	case ModeOptOut:
-		if annotations == nil {
-			return true
+		if found && value == "false" {
+			return false, ReasonOptedOut, rule
+		}
+		return true, ReasonNotOptedOut, rule
+	default: // always, and the default for unknown modes
+		return true, ReasonAlways, "mode always"
+	}
+}
+
+// HasKey reports whether the key or one of its aliases is set in the
+// configured source.
+func (c *AnnotationChecker) HasKey(labels, annotations map[string]string) bool {
+	_, _, found := c.lookup(labels, annotations)
+	return found
+}
+
+// DeprecatedKey returns the alias the decision is read from, if the primary
+// key is not set but a deprecated alias is.
+func (c *AnnotationChecker) DeprecatedKey(labels, annotations map[string]string) (string, bool) {
+	_, key, found := c.lookup(labels, annotations)
+	if !found || key == c.key {
+		return "", false
+	}
+	return key, true
+}
+
+// InvalidValue returns the key and value the decision is read from if the
+// value is neither "true" nor "false". It never reports in always mode.
+func (c *AnnotationChecker) InvalidValue(labels, annotations map[string]string) (string, string, bool) {
+	if c.mode == ModeAlways {
+		return "", "", false
+	}
+	value, key, found := c.lookup(labels, annotations)
+	if !found || value == "true" || value == "false" {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// Issues returns the problems with the key the decision is read from. It
+// never reports in always mode, where the key does not decide.
+func (c *AnnotationChecker) Issues(labels, annotations map[string]string) KeyIssues {
+	if c.mode == ModeAlways {
+		return KeyIssues{}
+	}
+	var issues KeyIssues
+	if alias, ok := c.DeprecatedKey(labels, annotations); ok {
+		issues.DeprecatedAlias, issues.Key = alias, c.key
+	}
+	if key, value, ok := c.InvalidValue(labels, annotations); ok {
+		issues.InvalidKey, issues.InvalidValue = key, value
+	}
+	return issues
+}
+
+// lookup returns the value and name of the first key set, trying the primary
+// key before the aliases.
+func (c *AnnotationChecker) lookup(labels, annotations map[string]string) (string, string, bool) {
+	if v, ok := c.lookupKey(c.key, labels, annotations); ok {
+		return v, c.key, true
+	}
+	for _, alias := range c.aliases {
+		if v, ok := c.lookupKey(alias, labels, annotations); ok {
+			return v, alias, true
+		}
+	}
+	return "", "", false
+}
+
+func (c *AnnotationChecker) lookupKey(key string, labels, annotations map[string]string) (string, bool) {
+	switch c.source {
+	case FromLabel:
+		v, ok := labels[key]
+		return v, ok
+	case FromEither:
+		if v, ok := labels[key]; ok {
+			return v, true
 		}
-		return annotations[c.key] != "false"
-	default:
-		return true // Default to always behavior
 	}
+	v, ok := annotations[key]
+	return v, ok
 }
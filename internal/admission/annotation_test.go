@@ -44,3 +44,66 @@ func TestAnnotationChecker_ShouldMutate(t *testing.T) {
 		})
 	}
 }
+
+func TestAnnotationChecker_ShouldMutateObject(t *testing.T) {
+	key := "change-ndots"
+	on := map[string]string{key: "true"}
+	off := map[string]string{key: "false"}
+
+	tests := []struct {
+		name        string
+		mode        string
+		source      string
+		labels      map[string]string
+		annotations map[string]string
+		want        bool
+	}{
+		{"annotation source ignores label", "opt-in", "annotation", on, nil, false},
+		{"annotation source reads annotation", "opt-in", "annotation", nil, on, true},
+		{"empty source defaults to annotation", "opt-in", "", nil, on, true},
+		{"label source reads label", "opt-in", "label", on, nil, true},
+		{"label source ignores annotation", "opt-in", "label", nil, on, false},
+		{"label source opt-out", "opt-out", "label", off, nil, false},
+		{"label source opt-out no label", "opt-out", "label", nil, off, true},
+		{"either reads annotation", "opt-in", "either", nil, on, true},
+		{"either reads label", "opt-in", "either", on, nil, true},
+		{"either prefers label over annotation", "opt-in", "either", off, on, false},
+		{"either prefers label opt-out", "opt-out", "either", on, off, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewAnnotationCheckerWithSource(key, tt.mode, tt.source)
+			assert.Equal(t, tt.want, checker.ShouldMutateObject(tt.labels, tt.annotations))
+		})
+	}
+}
+
+func TestAnnotationChecker_Aliases(t *testing.T) {
+	checker := NewAnnotationCheckerWithSource("ndots.example.com/change", "opt-in", "either").
+		WithAliases("change-ndots", "legacy.example.com/ndots")
+
+	tests := []struct {
+		name           string
+		labels         map[string]string
+		annotations    map[string]string
+		wantMutate     bool
+		wantDeprecated string
+	}{
+		{"primary key", nil, map[string]string{"ndots.example.com/change": "true"}, true, ""},
+		{"first alias", nil, map[string]string{"change-ndots": "true"}, true, "change-ndots"},
+		{"second alias label", map[string]string{"legacy.example.com/ndots": "true"}, nil, true, "legacy.example.com/ndots"},
+		{"primary key wins over alias", nil, map[string]string{"ndots.example.com/change": "false", "change-ndots": "true"}, false, ""},
+		{"earlier alias wins", nil, map[string]string{"change-ndots": "false", "legacy.example.com/ndots": "true"}, false, "change-ndots"},
+		{"no key", nil, nil, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantMutate, checker.ShouldMutateObject(tt.labels, tt.annotations))
+			alias, deprecated := checker.DeprecatedKey(tt.labels, tt.annotations)
+			assert.Equal(t, tt.wantDeprecated != "", deprecated)
+			assert.Equal(t, tt.wantDeprecated, alias)
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package admission
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Decision cache results, used as the metrics label.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+	// CacheBypass is a pod whose decision cannot be cached.
+	CacheBypass = "bypass"
+)
+
+// DecisionCache is a bounded LRU cache of mutator Decisions, so that the many
+// identical pods of a scale-up are decided on once. Entries are tied to the
+// revision of the cache they were computed in; Invalidate starts a new
+// revision. It is safe for concurrent use.
+type DecisionCache struct {
+	mu       sync.Mutex
+	size     int
+	revision uint64
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type cacheEntry struct {
+	key      string
+	decision Decision
+}
+
+// NewDecisionCache creates a cache holding the last size decisions.
+func NewDecisionCache(size int) *DecisionCache {
+	if size < 1 {
+		size = 1
+	}
+	return &DecisionCache{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+	}
+}
+
+// Get returns the Decision cached for key.
+func (c *DecisionCache) Get(key string) (Decision, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return Decision{}, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).decision, true
+}
+
+// Revision returns the current revision, to be passed to Add with a Decision
+// computed after the call.
+func (c *DecisionCache) Revision() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.revision
+}
+
+// Add caches d for key, evicting the least recently used decision if the
+// cache is full. d is dropped if the cache was invalidated since revision was
+// read, since it may have been computed with the old configuration.
+func (c *DecisionCache) Add(revision uint64, key string, d Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if revision != c.revision {
+		return
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry).decision = d
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, decision: d})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Invalidate drops all cached decisions and starts a new revision. It must be
+// called whenever configuration the decisions depend on changes, such as a
+// tenant policy.
+func (c *DecisionCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.revision++
+	clear(c.entries)
+	c.order.Init()
+}
+
+// Len returns the number of cached decisions.
+func (c *DecisionCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// SetDecisionCache caches the mutator's decisions in cache if the mutator
+// implements DecisionKeyer.
+func (h *Handler) SetDecisionCache(cache *DecisionCache) {
+	h.cache = cache
+}
+
+// decide returns the mutator's Decision for pod, from the decision cache if
+// possible. Errors are not cached.
+func (h *Handler) decide(ctx context.Context, req Request, pod *corev1.Pod) (Decision, error) {
+	keyer, ok := h.mutator.(DecisionKeyer)
+	if h.cache == nil || !ok {
+		return h.mutator.Mutate(ctx, req, pod)
+	}
+	key, ok := keyer.DecisionKey(req, pod)
+	if !ok {
+		h.recordDecisionCache(CacheBypass)
+		return h.mutator.Mutate(ctx, req, pod)
+	}
+
+	if d, ok := h.cache.Get(key); ok {
+		h.recordDecisionCache(CacheHit)
+		d.Patch = slices.Clone(d.Patch)
+		d.Steps = slices.Clone(d.Steps)
+		return d, nil
+	}
+	h.recordDecisionCache(CacheMiss)
+
+	revision := h.cache.Revision()
+	d, err := h.mutator.Mutate(ctx, req, pod)
+	if err != nil {
+		return Decision{}, err
+	}
+	h.cache.Add(revision, key, d)
+	return d, nil
+}
+
+// recordDecisionCache safely records a decision cache lookup if metrics is
+// configured.
+func (h *Handler) recordDecisionCache(result string) {
+	if h.metrics != nil {
+		h.metrics.RecordDecisionCache(result)
+	}
+}
+
+// decisionInput is everything a Mutator without expressions or owner lookups
+// decides on, apart from its configuration.
+type decisionInput struct {
+	Namespace   string               `json:"namespace"`
+	Labels      map[string]string    `json:"labels,omitempty"`
+	Annotations map[string]string    `json:"annotations,omitempty"`
+	DNSConfig   *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+	InitEnv     [][]corev1.EnvVar    `json:"initEnv,omitempty"`
+	Env         [][]corev1.EnvVar    `json:"env,omitempty"`
+}
+
+// DecisionKey returns a hash of the fields of pod the decision in
+// req.Namespace depends on: labels, annotations, the DNS config and, with
+// FQDN rewriting enabled, the env of every container. Decisions that depend
+// on state outside the pod are not cached: those made with policy
+// expressions, and those that may inherit the opt-in/opt-out key from an
+// owner. Tenant policy changes must invalidate the cache. It implements
+// DecisionKeyer.
+func (m *Mutator) DecisionKey(req Request, pod *corev1.Pod) (string, bool) {
+	if m.condition != nil || m.ndotsExpr != nil {
+		return "", false
+	}
+	namespace := req.Namespace
+	if m.owners != nil && !m.policyFor(namespace).checker.HasKey(pod.Labels, pod.Annotations) {
+		return "", false
+	}
+
+	in := decisionInput{
+		Namespace:   namespace,
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+		DNSConfig:   pod.Spec.DNSConfig,
+	}
+	if m.rewriteEnabled(namespace) {
+		for _, c := range pod.Spec.InitContainers {
+			in.InitEnv = append(in.InitEnv, c.Env)
+		}
+		for _, c := range pod.Spec.Containers {
+			in.Env = append(in.Env, c.Env)
+		}
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return namespace + "/" + hex.EncodeToString(sum[:]), true
+}
+
+// DecisionKey combines the keys of all links. The Decision can only be cached
+// if every link implements DecisionKeyer and can cache its own.
+func (c *Chain) DecisionKey(req Request, pod *corev1.Pod) (string, bool) {
+	var key strings.Builder
+	for _, link := range c.links {
+		keyer, ok := link.Mutator.(DecisionKeyer)
+		if !ok {
+			return "", false
+		}
+		k, ok := keyer.DecisionKey(req, pod)
+		if !ok {
+			return "", false
+		}
+		key.WriteString(link.Name + "=" + k + ";")
+	}
+	return key.String(), true
+}
@@ -0,0 +1,149 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestDecisionCache(t *testing.T) {
+	c := NewDecisionCache(2)
+	rev := c.Revision()
+
+	c.Add(rev, "a", Decision{Reason: ReasonAlways})
+	c.Add(rev, "b", Decision{Reason: ReasonOptedIn})
+	_, ok := c.Get("a") // a is now more recently used than b
+	require.True(t, ok)
+	c.Add(rev, "c", Decision{Reason: ReasonNotOptedOut})
+
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("b")
+	assert.False(t, ok, "least recently used entry must be evicted")
+	d, ok := c.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, ReasonAlways, d.Reason)
+
+	c.Invalidate()
+	assert.Equal(t, 0, c.Len())
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+
+	c.Add(rev, "a", Decision{Reason: ReasonAlways})
+	assert.Equal(t, 0, c.Len(), "decision computed before Invalidate must be dropped")
+	c.Add(c.Revision(), "a", Decision{Reason: ReasonAlways})
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestMutator_DecisionKey(t *testing.T) {
+	ndotsFive := "5"
+	replica := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"app": "web", "pod-template-hash": "7d9f8"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "DB", Value: "db.shop"}}}},
+			},
+		}
+	}
+	newMutator := func(cfg config.Config) *Mutator {
+		cfg.NdotsValue = 2
+		cfg.AnnotationKey = "change-ndots"
+		cfg.AnnotationMode = "opt-out"
+		return NewMutator(&cfg, slog.Default())
+	}
+	key := func(t *testing.T, m *Mutator, namespace string, pod *corev1.Pod) string {
+		k, ok := m.DecisionKey(Request{Namespace: namespace}, pod)
+		require.True(t, ok)
+		return k
+	}
+
+	m := newMutator(config.Config{})
+	base := key(t, m, "shop", replica("web-7d9f8-abcde"))
+	assert.Equal(t, base, key(t, m, "shop", replica("web-7d9f8-fghij")), "replicas share a key")
+	assert.NotEqual(t, base, key(t, m, "staging", replica("web-7d9f8-abcde")))
+
+	optedOut := replica("web-7d9f8-abcde")
+	optedOut.Annotations = map[string]string{"change-ndots": "false"}
+	assert.NotEqual(t, base, key(t, m, "shop", optedOut))
+
+	withNdots := replica("web-7d9f8-abcde")
+	withNdots.Spec.DNSConfig = &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndotsFive}}}
+	assert.NotEqual(t, base, key(t, m, "shop", withNdots))
+
+	otherEnv := replica("web-7d9f8-abcde")
+	otherEnv.Spec.Containers[0].Env[0].Value = "cache.shop"
+	assert.Equal(t, base, key(t, m, "shop", otherEnv), "env is irrelevant without FQDN rewriting")
+
+	rewriting := newMutator(config.Config{FQDNRewriteNamespaces: []string{"shop"}})
+	assert.NotEqual(t,
+		key(t, rewriting, "shop", replica("web-7d9f8-abcde")),
+		key(t, rewriting, "shop", otherEnv))
+
+	t.Run("expressions bypass the cache", func(t *testing.T) {
+		m := newMutator(config.Config{MutationCondition: "true", ExpressionCostLimit: 1000})
+		_, ok := m.DecisionKey(Request{Namespace: "shop"}, replica("web"))
+		assert.False(t, ok)
+	})
+
+	t.Run("owner inheritance bypasses the cache", func(t *testing.T) {
+		m := newMutator(config.Config{})
+		m.SetOwnerLookup(&stubOwnerLookup{})
+		_, ok := m.DecisionKey(Request{Namespace: "shop"}, replica("web"))
+		assert.False(t, ok, "pod without key may inherit it")
+		_, ok = m.DecisionKey(Request{Namespace: "shop"}, optedOut)
+		assert.True(t, ok, "pod with key does not look at its owners")
+	})
+}
+
+func TestHandler_DecisionCache(t *testing.T) {
+	m := NewMutator(&config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}, slog.Default())
+	chain := NewChain(slog.Default(), ChainLink{Name: "ndots", Mutator: m})
+	cache := NewDecisionCache(10)
+
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64"))
+	mockMetrics.On("RecordMutation", "shop", mock.Anything, "mutated", ReasonNotOptedOut)
+	mockMetrics.On("RecordDecisionCache", CacheMiss).Once()
+	mockMetrics.On("RecordDecisionCache", CacheHit).Twice()
+	h := NewHandlerWithMetrics(chain, slog.Default(), mockMetrics)
+	h.SetDecisionCache(cache)
+
+	admit := func(name string) *admissionv1.AdmissionResponse {
+		review := createValidAdmissionReview(name, "shop")
+		review.Request.Object.Raw = []byte(`{"metadata":{"name":"` + name + `","labels":{"pod-template-hash":"7d9f8"}},"spec":{"containers":[{"name":"app"}]}}`)
+		body, _ := json.Marshal(review)
+		w := httptest.NewRecorder()
+		h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+
+		var resp admissionv1.AdmissionReview
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Response
+	}
+
+	first := admit("web-7d9f8-abcde")
+	assert.Equal(t, 1, cache.Len())
+	second := admit("web-7d9f8-fghij")
+	assert.Equal(t, first.Patch, second.Patch)
+	assert.NotEmpty(t, second.Patch)
+
+	// A cleared cache still answers the next request; the decision is
+	// computed again.
+	cache.Invalidate()
+	mockMetrics.On("RecordDecisionCache", CacheMiss).Once()
+	assert.Equal(t, first.Patch, admit("web-7d9f8-klmno").Patch)
+	assert.Equal(t, first.Patch, admit("web-7d9f8-pqrst").Patch)
+	mockMetrics.AssertExpectations(t)
+}
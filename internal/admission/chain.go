@@ -0,0 +1,151 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ChainLink is a named mutator in a Chain.
+type ChainLink struct {
+	Name    string
+	Mutator PodMutator
+}
+
+// Step is the outcome of one ChainLink for a pod.
+type Step struct {
+	Name    string     `json:"name"`
+	Outcome Outcome    `json:"outcome"`
+	Reason  ReasonCode `json:"reason,omitempty"`
+	Rule    string     `json:"rule,omitempty"`
+	Ops     int        `json:"ops"`
+}
+
+// Chain runs several independent mutators on a pod and merges their patches
+// into one. Links run in order, each on the pod as patched by the links
+// before it, so a link sees the /spec/dnsConfig an earlier link added and its
+// operations apply cleanly after the earlier ones.
+type Chain struct {
+	links  []ChainLink
+	logger *slog.Logger
+}
+
+// NewChain creates a Chain of links.
+func NewChain(logger *slog.Logger, links ...ChainLink) *Chain {
+	return &Chain{links: links, logger: logger}
+}
+
+// Mutate runs every link and returns the merged Decision. Its reason, policy
+// and rule are those of the first link that mutates the pod, or of the first
+// link if none does. NdotsAfter is that of the link whose patch changes the
+// pod's ndots option, so it need not be the first one. Steps has the outcome
+// of each link and KeyIssues are those of the first link reporting any. A
+// link that overwrites a path written by an earlier link fails the whole
+// chain.
+func (c *Chain) Mutate(ctx context.Context, req Request, pod *corev1.Pod) (Decision, error) {
+	var (
+		primary    Decision
+		found      bool
+		issues     KeyIssues
+		patch      []PatchOperation
+		steps      = make([]Step, 0, len(c.links))
+		owners     = map[string]string{}
+		current    = pod
+		ndotsAfter = currentNdots(pod)
+	)
+
+	for i, link := range c.links {
+		if err := ctx.Err(); err != nil {
+			return Decision{}, err
+		}
+
+		d, err := link.Mutator.Mutate(ctx, req, current)
+		if err != nil {
+			return Decision{}, fmt.Errorf("mutator %s: %w", link.Name, err)
+		}
+		steps = append(steps, Step{Name: link.Name, Outcome: d.Outcome, Reason: d.Reason, Rule: d.Rule, Ops: len(d.Patch)})
+		c.logger.Debug("mutator step",
+			"namespace", req.Namespace,
+			"name", getPodName(pod),
+			"mutator", link.Name,
+			"outcome", d.Outcome,
+			"ops", len(d.Patch),
+		)
+		if i == 0 || (!found && d.Mutates()) {
+			primary = d
+			found = d.Mutates()
+		}
+		if issues == (KeyIssues{}) {
+			issues = d.KeyIssues
+		}
+		if !d.Mutates() || len(d.Patch) == 0 {
+			continue
+		}
+
+		for _, op := range d.Patch {
+			if other, path, ok := overwrites(op, owners); ok {
+				return Decision{}, newError(CodePatchBuild, fmt.Errorf("mutator %s conflicts with %s on %s", link.Name, other, path))
+			}
+		}
+		for _, op := range d.Patch {
+			if !strings.HasSuffix(op.Path, "/-") {
+				owners[op.Path] = link.Name
+			}
+		}
+		patch = append(patch, d.Patch...)
+
+		before := currentNdots(current)
+		if current, err = applyPatch(current, d.Patch); err != nil {
+			return Decision{}, newError(CodePatchBuild, fmt.Errorf("mutator %s: %w", link.Name, err))
+		}
+		if currentNdots(current) != before {
+			ndotsAfter = d.NdotsAfter
+		}
+	}
+
+	primary.NdotsBefore = currentNdots(pod)
+	primary.NdotsAfter = ndotsAfter
+	primary.Patch = patch
+	primary.Steps = steps
+	primary.KeyIssues = issues
+	primary.Outcome = OutcomeSkip
+	if len(patch) > 0 {
+		primary.Outcome = OutcomeMutate
+	}
+	return primary, nil
+}
+
+// overwrites reports whether op overwrites a path in owners, which maps the
+// paths written so far to the link that wrote them. Operations on the same
+// path or on an ancestor of it overwrite; so do replace and remove below it.
+// Adding below a written path extends it, and appending to an array never
+// conflicts.
+func overwrites(op PatchOperation, owners map[string]string) (string, string, bool) {
+	if strings.HasSuffix(op.Path, "/-") {
+		return "", "", false
+	}
+	for path, owner := range owners {
+		switch {
+		case op.Path == path, strings.HasPrefix(path, op.Path+"/"):
+			return owner, path, true
+		case op.Op != "add" && strings.HasPrefix(op.Path, path+"/"):
+			return owner, path, true
+		}
+	}
+	return "", "", false
+}
+
+// Lint reports the hostname findings of every link that implements
+// PodLinter.
+func (c *Chain) Lint(namespace string, pod *corev1.Pod, ndots int) []HostnameFinding {
+	var findings []HostnameFinding
+	for _, link := range c.links {
+		if linter, ok := link.Mutator.(PodLinter); ok {
+			findings = append(findings, linter.Lint(namespace, pod, ndots)...)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,195 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+// mutatorFunc adapts a function to PodMutator.
+type mutatorFunc func(pod *corev1.Pod) (Decision, error)
+
+func (f mutatorFunc) Mutate(_ context.Context, _ Request, pod *corev1.Pod) (Decision, error) {
+	return f(pod)
+}
+
+// searchDomain adds a search domain, creating /spec/dnsConfig if needed.
+func searchDomain(domain string) mutatorFunc {
+	return func(pod *corev1.Pod) (Decision, error) {
+		op := PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{"searches": []string{domain}}}
+		if pod.Spec.DNSConfig != nil {
+			op = PatchOperation{Op: "add", Path: "/spec/dnsConfig/searches", Value: append(pod.Spec.DNSConfig.Searches, domain)}
+		}
+		return Decision{Outcome: OutcomeMutate, Reason: ReasonAlways, Patch: []PatchOperation{op}}, nil
+	}
+}
+
+func TestChain_Mutate(t *testing.T) {
+	ndots := NewMutator(&config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}, slog.Default())
+	req := Request{Namespace: "default"}
+
+	t.Run("merges patches", func(t *testing.T) {
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "ndots", Mutator: ndots},
+			ChainLink{Name: "search", Mutator: searchDomain("corp.example")},
+		)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, OutcomeMutate, d.Outcome)
+		assert.Equal(t, ReasonNotOptedOut, d.Reason)
+		assert.Equal(t, []Step{
+			{Name: "ndots", Outcome: OutcomeMutate, Reason: ReasonNotOptedOut, Rule: "change-ndots unset", Ops: 1},
+			{Name: "search", Outcome: OutcomeMutate, Reason: ReasonAlways, Ops: 1},
+		}, d.Steps)
+
+		patched, err := applyPatch(pod, d.Patch)
+		require.NoError(t, err)
+		require.NotNil(t, patched.Spec.DNSConfig)
+		assert.Equal(t, []string{"corp.example"}, patched.Spec.DNSConfig.Searches)
+		assert.Equal(t, 2, currentNdots(patched))
+		assert.Nil(t, pod.Spec.DNSConfig, "pod must not be modified")
+	})
+
+	t.Run("later link mutates", func(t *testing.T) {
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "ndots", Mutator: ndots},
+			ChainLink{Name: "search", Mutator: searchDomain("corp.example")},
+		)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"change-ndots": "false"}}}
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, OutcomeMutate, d.Outcome)
+		assert.Equal(t, ReasonAlways, d.Reason)
+		assert.Equal(t, OutcomeSkip, d.Steps[0].Outcome)
+		assert.Len(t, d.Patch, 1)
+	})
+
+	t.Run("ndots from the link changing it", func(t *testing.T) {
+		search := NewSearchDomains(&config.Config{SearchDomains: []string{"corp.example.com"}}, slog.Default())
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "search", Mutator: search},
+			ChainLink{Name: "ndots", Mutator: ndots},
+		)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+		raw, err := json.Marshal(pod)
+		require.NoError(t, err)
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, ReasonAlways, d.Reason, "reason of the first mutating link")
+		assert.Equal(t, 5, d.NdotsBefore)
+		assert.Equal(t, 2, d.NdotsAfter)
+
+		patch, err := json.Marshal(d.Patch)
+		require.NoError(t, err)
+		assert.NoError(t, verifyPatch(raw, patch, pod, d))
+	})
+
+	t.Run("ndots unchanged", func(t *testing.T) {
+		search := NewSearchDomains(&config.Config{SearchDomains: []string{"corp.example.com"}}, slog.Default())
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "ndots", Mutator: ndots},
+			ChainLink{Name: "search", Mutator: search},
+		)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"change-ndots": "false"}}}
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, OutcomeMutate, d.Outcome)
+		assert.Equal(t, 5, d.NdotsBefore)
+		assert.Equal(t, 5, d.NdotsAfter)
+	})
+
+	t.Run("no link mutates", func(t *testing.T) {
+		chain := NewChain(slog.Default(), ChainLink{Name: "ndots", Mutator: ndots})
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"change-ndots": "false"}}}
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.False(t, d.Mutates())
+		assert.Equal(t, ReasonOptedOut, d.Reason)
+		assert.Empty(t, d.Patch)
+	})
+
+	t.Run("key issues of a later link", func(t *testing.T) {
+		aliased := NewMutator(&config.Config{
+			NdotsValue:           2,
+			AnnotationKey:        "ndots.example.com/change",
+			AnnotationKeyAliases: []string{"change-ndots"},
+			AnnotationMode:       "opt-out",
+		}, slog.Default())
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "search", Mutator: searchDomain("corp.example")},
+			ChainLink{Name: "ndots", Mutator: aliased},
+		)
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"change-ndots": "no"}}}
+
+		d, err := chain.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, ReasonAlways, d.Reason)
+		assert.Equal(t, KeyIssues{
+			DeprecatedAlias: "change-ndots", Key: "ndots.example.com/change",
+			InvalidKey: "change-ndots", InvalidValue: "no",
+		}, d.KeyIssues)
+	})
+
+	t.Run("conflicting paths", func(t *testing.T) {
+		replaceDNS := mutatorFunc(func(*corev1.Pod) (Decision, error) {
+			return Decision{Outcome: OutcomeMutate, Patch: []PatchOperation{
+				{Op: "replace", Path: "/spec/dnsConfig", Value: map[string]interface{}{}},
+			}}, nil
+		})
+		chain := NewChain(slog.Default(),
+			ChainLink{Name: "ndots", Mutator: ndots},
+			ChainLink{Name: "reset", Mutator: replaceDNS},
+		)
+
+		_, err := chain.Mutate(context.Background(), req, &corev1.Pod{})
+		assert.EqualError(t, err, "failed to build patch: mutator reset conflicts with ndots on /spec/dnsConfig")
+		assert.Equal(t, CodePatchBuild, asError(err, CodePolicy).Code)
+	})
+
+	t.Run("link error", func(t *testing.T) {
+		chain := NewChain(slog.Default(), ChainLink{Name: "broken", Mutator: mutatorFunc(func(*corev1.Pod) (Decision, error) {
+			return Decision{}, errors.New("boom")
+		})})
+
+		_, err := chain.Mutate(context.Background(), req, &corev1.Pod{})
+		assert.EqualError(t, err, "mutator broken: boom")
+	})
+}
+
+func TestOverwrites(t *testing.T) {
+	owners := map[string]string{"/spec/dnsConfig": "ndots"}
+
+	tests := []struct {
+		op   PatchOperation
+		want bool
+	}{
+		{PatchOperation{Op: "add", Path: "/spec/dnsConfig"}, true},
+		{PatchOperation{Op: "remove", Path: "/spec"}, true},
+		{PatchOperation{Op: "replace", Path: "/spec/dnsConfig/options/0/value"}, true},
+		{PatchOperation{Op: "add", Path: "/spec/dnsConfig/searches"}, false},
+		{PatchOperation{Op: "add", Path: "/spec/dnsConfig/options/-"}, false},
+		{PatchOperation{Op: "add", Path: "/metadata/annotations"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op.Op+" "+tt.op.Path, func(t *testing.T) {
+			_, _, got := overwrites(tt.op, owners)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
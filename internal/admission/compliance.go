@@ -0,0 +1,73 @@
+package admission
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Compliance classifies an existing pod against the ndots policy.
+type Compliance string
+
+const (
+	// Compliant pods are subject to the policy and already use its ndots value.
+	Compliant Compliance = "compliant"
+	// NonCompliant pods are subject to the policy but would be mutated.
+	NonCompliant Compliance = "non-compliant"
+	// Exempt pods are excluded by the namespace filter or a tenant exemption.
+	Exempt Compliance = "exempt"
+	// Skipped pods are opted out, or not opted in, via the opt-in/opt-out key.
+	Skipped Compliance = "skipped"
+)
+
+// ComplianceResult is the outcome of checking one pod.
+type ComplianceResult struct {
+	Compliance Compliance
+	// Reason explains an Exempt or Skipped result.
+	Reason string
+	// Ndots is the pod's effective ndots value.
+	Ndots int
+	// Want is the ndots value the policy sets in the pod's namespace; zero
+	// for pods excluded by the namespace filter.
+	Want int
+}
+
+// Check classifies pod with the same decision Mutate makes at admission.
+// Only the ndots option is considered, not FQDN rewriting. Pods the policy
+// expressions fail on are Skipped, as they are admitted unchanged.
+func (m *Mutator) Check(pod *corev1.Pod) ComplianceResult {
+	p, d, err := m.scope(context.Background(), Request{Namespace: pod.Namespace}, pod, nil)
+	if err != nil {
+		return ComplianceResult{Compliance: Skipped, Reason: "expression error", Ndots: currentNdots(pod), Want: p.ndots}
+	}
+	result := ComplianceResult{
+		Ndots: d.NdotsBefore,
+		Want:  p.ndots,
+	}
+	if !d.Mutates() {
+		result.Compliance, result.Reason = complianceOf(d.Reason)
+		return result
+	}
+
+	if !setNdots(pod.DeepCopy(), p.ndotsValue) {
+		result.Compliance = Compliant
+	} else {
+		result.Compliance = NonCompliant
+	}
+	return result
+}
+
+// complianceOf returns the Compliance and its reason for a pod skipped with
+// reason.
+func complianceOf(reason ReasonCode) (Compliance, string) {
+	switch reason {
+	case ReasonNamespaceExcluded, ReasonNamespaceNotIncluded:
+		return Exempt, "namespace filter"
+	case ReasonPolicyExemption:
+		return Exempt, "namespace policy exemption"
+	case ReasonConditionNotMet:
+		return Skipped, "mutation condition"
+	default:
+		return Skipped, "annotation"
+	}
+}
@@ -0,0 +1,63 @@
+package admission
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestMutator_Check(t *testing.T) {
+	m := NewMutator(&config.Config{
+		NdotsValue:       2,
+		AnnotationKey:    "change-ndots",
+		AnnotationMode:   "opt-out",
+		NamespaceExclude: []string{"kube-system"},
+	}, slog.Default())
+
+	two := "2"
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want ComplianceResult
+	}{
+		{
+			name: "default dns config",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop"}},
+			want: ComplianceResult{Compliance: NonCompliant, Ndots: 5, Want: 2},
+		},
+		{
+			name: "already mutated",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop"},
+				Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+					Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &two}},
+				}},
+			},
+			want: ComplianceResult{Compliance: Compliant, Ndots: 2, Want: 2},
+		},
+		{
+			name: "opted out",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "shop",
+				Annotations: map[string]string{"change-ndots": "false"},
+			}},
+			want: ComplianceResult{Compliance: Skipped, Reason: "annotation", Ndots: 5, Want: 2},
+		},
+		{
+			name: "excluded namespace",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system"}},
+			want: ComplianceResult{Compliance: Exempt, Reason: "namespace filter", Ndots: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, m.Check(tt.pod))
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package admission
+
+// Outcome is what the mutator does with a pod.
+type Outcome string
+
+const (
+	// OutcomeMutate pods are admitted with the decision's patch.
+	OutcomeMutate Outcome = "mutate"
+	// OutcomeSkip pods are admitted unchanged.
+	OutcomeSkip Outcome = "skip"
+)
+
+// ReasonCode is the machine-readable reason for a Decision. It is used as a
+// metrics label, so the set of values is fixed.
+type ReasonCode string
+
+// Reasons for skipping a pod.
+const (
+	ReasonNamespaceExcluded    ReasonCode = "namespace-excluded"
+	ReasonNamespaceNotIncluded ReasonCode = "namespace-not-included"
+	ReasonPolicyExemption      ReasonCode = "policy-exemption"
+	ReasonOptedOut             ReasonCode = "opted-out"
+	ReasonNotOptedIn           ReasonCode = "not-opted-in"
+	ReasonAlreadyCompliant     ReasonCode = "already-compliant"
+	ReasonConditionNotMet      ReasonCode = "condition-not-met"
+)
+
+// Reasons for mutating a pod.
+const (
+	ReasonAlways      ReasonCode = "always"
+	ReasonOptedIn     ReasonCode = "opted-in"
+	ReasonNotOptedOut ReasonCode = "not-opted-out"
+)
+
+// Policies a Decision can be made by.
+const (
+	// PolicyCluster is the webhook's own configuration.
+	PolicyCluster = "cluster"
+	// PolicyNamespace is a per-namespace override from a PolicySource.
+	PolicyNamespace = "namespace"
+)
+
+// Decision is the result of evaluating a pod against the ndots policy.
+type Decision struct {
+	Outcome Outcome
+	Reason  ReasonCode
+	// Policy is PolicyCluster or PolicyNamespace; empty when the namespace
+	// filter decided.
+	Policy string
+	// Rule is the rule that matched in readable form, such as
+	// `change-ndots="false" on owner web`.
+	Rule string
+	// NdotsBefore is the pod's effective ndots value, NdotsAfter the value
+	// once Patch is applied.
+	NdotsBefore int
+	NdotsAfter  int
+	// Patch is set for OutcomeMutate.
+	Patch []PatchOperation
+	// Steps has the outcome of each mutator when the pod went through a
+	// Chain.
+	Steps []Step
+	// KeyIssues are problems with the opt-in/opt-out key the decision was
+	// read from. They are only set if the key decided the outcome.
+	KeyIssues KeyIssues
+}
+
+// KeyIssues are problems with the opt-in/opt-out key a Decision was read
+// from, reported to the pod's owner as warnings and Events.
+type KeyIssues struct {
+	// DeprecatedAlias is the deprecated alias the key was read from, and Key
+	// the key that replaces it.
+	DeprecatedAlias string
+	Key             string
+	// InvalidKey is the key or alias whose value InvalidValue is neither
+	// "true" nor "false".
+	InvalidKey   string
+	InvalidValue string
+}
+
+// Mutates reports whether the decision changes the pod.
+func (d Decision) Mutates() bool {
+	return d.Outcome == OutcomeMutate
+}
+
+// skipDecision returns the Decision to leave a pod with ndots unchanged.
+func skipDecision(reason ReasonCode, policy, rule string, ndots int) Decision {
+	return Decision{
+		Outcome:     OutcomeSkip,
+		Reason:      reason,
+		Policy:      policy,
+		Rule:        rule,
+		NdotsBefore: ndots,
+		NdotsAfter:  ndots,
+	}
+}
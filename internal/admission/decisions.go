@@ -0,0 +1,112 @@
+package admission
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DecisionRecord is one admission decision kept for debugging.
+type DecisionRecord struct {
+	Time      time.Time `json:"time"`
+	UID       types.UID `json:"uid"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Workload  string    `json:"workload"`
+	// Decision is the metrics action ("mutated", "skipped", "audit", "warn")
+	// or "error".
+	Decision string `json:"decision"`
+	// Reason is the Decision's ReasonCode, Rule its rule or, for errors, the
+	// error message.
+	Reason      string `json:"reason,omitempty"`
+	Rule        string `json:"rule"`
+	NdotsBefore int    `json:"ndotsBefore"`
+	NdotsAfter  int    `json:"ndotsAfter"`
+	// Steps has the outcome of each mutator of a Chain.
+	Steps []Step `json:"steps,omitempty"`
+}
+
+// DecisionRing keeps the most recent decisions in a fixed-size buffer. It is
+// safe for concurrent use.
+type DecisionRing struct {
+	mu      sync.Mutex
+	records []DecisionRecord
+	next    int
+	full    bool
+}
+
+// NewDecisionRing creates a ring holding the last size decisions.
+func NewDecisionRing(size int) *DecisionRing {
+	if size < 1 {
+		size = 1
+	}
+	return &DecisionRing{records: make([]DecisionRecord, size)}
+}
+
+// Add stores rec, evicting the oldest record if the ring is full.
+func (r *DecisionRing) Add(rec DecisionRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[r.next] = rec
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List returns up to limit records matching namespace and decision, newest
+// first. Empty filters match everything; a limit below 1 means no limit.
+func (r *DecisionRing) List(namespace, decision string, limit int) []DecisionRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.next
+	if r.full {
+		n = len(r.records)
+	}
+
+	out := []DecisionRecord{}
+	for i := 1; i <= n; i++ {
+		rec := r.records[(r.next-i+len(r.records))%len(r.records)]
+		if namespace != "" && rec.Namespace != namespace {
+			continue
+		}
+		if decision != "" && rec.Decision != decision {
+			continue
+		}
+		out = append(out, rec)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// ServeHTTP returns the recorded decisions as JSON, filtered by the
+// namespace, decision and limit query parameters. It does not authenticate
+// callers; wrap it accordingly.
+func (r *DecisionRing) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r.List(query.Get("namespace"), query.Get("decision"), limit))
+}
@@ -0,0 +1,70 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecisionRing(t *testing.T) {
+	ring := NewDecisionRing(3)
+	assert.Empty(t, ring.List("", "", 0))
+
+	for i := 0; i < 5; i++ {
+		decision := "mutated"
+		if i%2 == 1 {
+			decision = "skipped"
+		}
+		ring.Add(DecisionRecord{Namespace: fmt.Sprintf("ns-%d", i%2), Name: fmt.Sprintf("pod-%d", i), Decision: decision})
+	}
+
+	names := func(records []DecisionRecord) []string {
+		var out []string
+		for _, r := range records {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"pod-4", "pod-3", "pod-2"}, names(ring.List("", "", 0)))
+	assert.Equal(t, []string{"pod-4", "pod-2"}, names(ring.List("ns-0", "", 0)))
+	assert.Equal(t, []string{"pod-3"}, names(ring.List("", "skipped", 0)))
+	assert.Empty(t, ring.List("ns-1", "mutated", 0))
+	assert.Equal(t, []string{"pod-4"}, names(ring.List("", "", 1)))
+}
+
+func TestDecisionRing_ServeHTTP(t *testing.T) {
+	ring := NewDecisionRing(10)
+	ring.Add(DecisionRecord{Namespace: "shop", Name: "a", Decision: "mutated"})
+	ring.Add(DecisionRecord{Namespace: "shop", Name: "b", Decision: "skipped"})
+	ring.Add(DecisionRecord{Namespace: "payments", Name: "c", Decision: "mutated"})
+
+	t.Run("filtered", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ring.ServeHTTP(w, httptest.NewRequest("GET", "/debug/decisions?namespace=shop&decision=mutated", nil))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		var records []DecisionRecord
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+		require.Len(t, records, 1)
+		assert.Equal(t, "a", records[0].Name)
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ring.ServeHTTP(w, httptest.NewRequest("GET", "/debug/decisions?limit=all", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		ring.ServeHTTP(w, httptest.NewRequest("POST", "/debug/decisions", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ErrorCode is the stable code of an admission processing error. It is the
+// "type" label of the error metrics and the "errorCode" log field, and
+// selects the HTTP status and the AdmissionResponse status of the error.
+type ErrorCode string
+
+const (
+	// CodeRead is a request body that cannot be read or is empty.
+	CodeRead ErrorCode = "read"
+	// CodeTooLarge is a request body over maxRequestBody.
+	CodeTooLarge ErrorCode = "too-large"
+	// CodeContentType is a request body that is not JSON.
+	CodeContentType ErrorCode = "content-type"
+	// CodeDecodeReview is a body that is not an AdmissionReview with a
+	// request.
+	CodeDecodeReview ErrorCode = "decode-review"
+	// CodeDecodeObject is an admitted object that is not a Pod.
+	CodeDecodeObject ErrorCode = "decode-object"
+	// CodePolicy is a failure to decide on the pod, e.g. an expression error.
+	CodePolicy ErrorCode = "policy"
+	// CodePatchBuild is a failure to build the patch, e.g. conflicting
+	// mutators.
+	CodePatchBuild ErrorCode = "patch-build"
+	// CodePatchVerify is a patch that does not apply to the admitted object or
+	// does not produce the intended pod.
+	CodePatchVerify ErrorCode = "patch-verify"
+	// CodeMarshal is a patch or response that cannot be encoded.
+	CodeMarshal ErrorCode = "marshal"
+	// CodeTimeout is a request whose deadline passed or that was canceled.
+	CodeTimeout ErrorCode = "timeout"
+)
+
+// errorStatus is the HTTP status code and the StatusReason of each code.
+var errorStatus = map[ErrorCode]struct {
+	code   int32
+	reason metav1.StatusReason
+	text   string
+}{
+	CodeRead:         {http.StatusBadRequest, metav1.StatusReasonBadRequest, "failed to read body"},
+	CodeTooLarge:     {http.StatusRequestEntityTooLarge, metav1.StatusReasonRequestEntityTooLarge, "request body too large"},
+	CodeContentType:  {http.StatusUnsupportedMediaType, metav1.StatusReasonUnsupportedMediaType, "unsupported content type"},
+	CodeDecodeReview: {http.StatusBadRequest, metav1.StatusReasonBadRequest, "failed to decode admission review"},
+	CodeDecodeObject: {http.StatusBadRequest, metav1.StatusReasonBadRequest, "failed to decode pod"},
+	CodePolicy:       {http.StatusInternalServerError, metav1.StatusReasonInternalError, "mutation failed"},
+	CodePatchBuild:   {http.StatusInternalServerError, metav1.StatusReasonInternalError, "failed to build patch"},
+	CodePatchVerify:  {http.StatusInternalServerError, metav1.StatusReasonInternalError, "patch verification failed"},
+	CodeMarshal:      {http.StatusInternalServerError, metav1.StatusReasonInternalError, "failed to marshal"},
+	CodeTimeout:      {http.StatusGatewayTimeout, metav1.StatusReasonTimeout, "request timed out"},
+}
+
+// HTTPStatus returns the HTTP status code of errors with code c.
+func (c ErrorCode) HTTPStatus() int {
+	if s, ok := errorStatus[c]; ok {
+		return int(s.code)
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is an admission processing error with a stable code.
+type Error struct {
+	Code ErrorCode
+	Err  error
+}
+
+// newError returns err as an Error with code.
+func newError(code ErrorCode, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	return errorStatus[e.Code].text + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the error as the status of a denied AdmissionResponse.
+func (e *Error) Status() *metav1.Status {
+	s := errorStatus[e.Code]
+	return &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Message: e.Error(),
+		Reason:  s.reason,
+		Code:    s.code,
+	}
+}
+
+// asError returns err as an Error. Errors without a code are classified as
+// CodeTimeout if caused by the request context, and as fallback otherwise.
+func asError(err error, fallback ErrorCode) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return newError(CodeTimeout, err)
+	}
+	return newError(fallback, err)
+}
@@ -0,0 +1,100 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAsError(t *testing.T) {
+	verify := newError(CodePatchVerify, errors.New("apply patch"))
+
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"typed", verify, CodePatchVerify},
+		{"wrapped typed", fmt.Errorf("mutator ndots: %w", verify), CodePatchVerify},
+		{"deadline", fmt.Errorf("evaluate ndots expression: %w", context.DeadlineExceeded), CodeTimeout},
+		{"canceled", context.Canceled, CodeTimeout},
+		{"untyped", errors.New("boom"), CodePolicy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, asError(tt.err, CodePolicy).Code)
+		})
+	}
+}
+
+func TestHandler_HTTPErrors(t *testing.T) {
+	review, _ := json.Marshal(createValidAdmissionReview("web", "default"))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantCode    ErrorCode
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "unsupported content type",
+			contentType: "application/yaml",
+			body:        review,
+			wantCode:    CodeContentType,
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantBody:    `unsupported content type: got "application/yaml", want application/json`,
+		},
+		{
+			name:        "empty body",
+			contentType: "application/json; charset=utf-8",
+			wantCode:    CodeRead,
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    "failed to read body: empty body",
+		},
+		{
+			name:       "body too large",
+			body:       bytes.Repeat([]byte(" "), maxRequestBody+1),
+			wantCode:   CodeTooLarge,
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   "request body too large: http: request body too large",
+		},
+		{
+			name:       "review without request",
+			body:       []byte(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`),
+			wantCode:   CodeDecodeReview,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "failed to decode admission review: admission review request is nil",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMetrics := new(MockMetricsRecorder)
+			mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+			mockMetrics.On("RecordError", tt.wantCode).Once()
+			h := NewHandlerWithMetrics(new(MockMutator), slog.Default(), mockMetrics)
+
+			req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			h.HandleMutate(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody+"\n", w.Body.String())
+			mockMetrics.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package admission
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Event reasons recorded on the owners of pods by the handler.
+const (
+	ReasonMutated         = "NdotsMutated"
+	ReasonDeferred        = "NdotsMutationDeferred"
+	ReasonInvalidKeyValue = "InvalidNdotsKeyValue"
+)
+
+// event records an event if an event recorder is configured. Dry-run
+// requests record nothing.
+func (h *Handler) event(req Request, pod *corev1.Pod, eventType, reason, message string) {
+	if h.events != nil && !req.DryRun {
+		h.events.PodEvent(pod, req.Namespace, eventType, reason, message)
+	}
+}
+
+// invalidKeyEvent records a warning event if the opt-in/opt-out key the
+// pod's decision is read from has a value the mutator does not understand.
+func (h *Handler) invalidKeyEvent(req Request, pod *corev1.Pod, decision Decision) {
+	if key := decision.KeyIssues.InvalidKey; key != "" {
+		h.event(req, pod, corev1.EventTypeWarning, ReasonInvalidKeyValue,
+			fmt.Sprintf("ndots key %q has invalid value %q, expected \"true\" or \"false\"", key, decision.KeyIssues.InvalidValue))
+	}
+}
+
+// describePatch summarizes a mutation for event messages.
+func describePatch(patch []PatchOperation) string {
+	var parts []string
+	rewrites := 0
+	for _, op := range patch {
+		if !strings.HasPrefix(op.Path, "/spec/dnsConfig") {
+			rewrites++
+			continue
+		}
+		if ndots, ok := patchNdots(op.Value); ok {
+			parts = append(parts, "set DNS option ndots to "+ndots)
+		}
+	}
+	if rewrites > 0 {
+		parts = append(parts, fmt.Sprintf("rewrote %d env value(s) to FQDNs", rewrites))
+	}
+	if len(parts) == 0 {
+		return "mutated DNS config"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// patchNdots extracts the ndots value from the value of a dnsConfig patch
+// operation as built by CreatePatch.
+func patchNdots(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case map[string]interface{}:
+		if name, ok := v["name"]; ok {
+			if name != "ndots" {
+				return "", false
+			}
+			s, ok := v["value"].(string)
+			return s, ok
+		}
+		return patchNdots(v["options"])
+	case []interface{}:
+		for _, opt := range v {
+			if s, ok := patchNdots(opt); ok {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
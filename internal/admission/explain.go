@@ -0,0 +1,307 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Trace describes how the mutator evaluates one pod, stage by stage. Stages
+// after the one that ends the evaluation are left empty.
+type Trace struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Workload  string `json:"workload"`
+
+	NamespaceFilter NamespaceFilterTrace `json:"namespaceFilter"`
+	NamespacePolicy *PolicyTrace         `json:"namespacePolicy,omitempty"`
+	Key             *KeyTrace            `json:"key,omitempty"`
+
+	DNSPolicy corev1.DNSPolicy     `json:"dnsPolicy"`
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	Decision   Outcome          `json:"decision"`
+	Reason     ReasonCode       `json:"reason"`
+	Policy     string           `json:"policy,omitempty"`
+	Rule       string           `json:"rule"`
+	Patch      []PatchOperation `json:"patch,omitempty"`
+	ResolvConf string           `json:"resolvConf"`
+	// Error is set if the evaluation failed; admission then answers as
+	// FailureMode says.
+	Error string `json:"error,omitempty"`
+	// FailureMode is how admission answers the pod if it fails on it. It is
+	// only set by ExplainHandler.
+	FailureMode FailureMode `json:"failureMode,omitempty"`
+}
+
+// NamespaceFilterTrace is the result of the namespace include/exclude lists.
+type NamespaceFilterTrace struct {
+	Mutate bool   `json:"mutate"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// PolicyTrace is the policy in effect in the pod's namespace.
+type PolicyTrace struct {
+	Mode  AnnotationMode `json:"mode"`
+	Ndots int            `json:"ndots"`
+	// Tenant is set if a namespace policy overrides the cluster-wide one.
+	Tenant bool `json:"tenant"`
+	// Exempt is set if the pod matches the namespace policy's exemption.
+	Exempt bool `json:"exempt"`
+}
+
+// KeyTrace is the opt-in/opt-out key as seen by the mutator.
+type KeyTrace struct {
+	Key    string           `json:"key"`
+	Source AnnotationSource `json:"source"`
+	// InheritedFrom names the owner the key was read from, if not the pod.
+	InheritedFrom string `json:"inheritedFrom,omitempty"`
+	Found         bool   `json:"found"`
+	// FoundKey is the key or deprecated alias that was found.
+	FoundKey string `json:"foundKey,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// quietLogger discards the logs of the admission code path run by Explain.
+var quietLogger = slog.New(slog.DiscardHandler)
+
+// Explain evaluates pod like Mutate and returns every stage of the
+// evaluation. It has no side effects: it neither logs nor records metrics.
+func (m *Mutator) Explain(ctx context.Context, pod *corev1.Pod) Trace {
+	d, err := m.decide(ctx, Request{Namespace: pod.Namespace}, pod, nil, quietLogger)
+	dnsConfig := pod.Spec.DNSConfig
+	if d.Mutates() {
+		dnsConfig = withNdots(dnsConfig, strconv.Itoa(d.NdotsAfter))
+	}
+
+	t := Trace{
+		Namespace:  pod.Namespace,
+		Name:       getPodName(pod),
+		Workload:   WorkloadOf(pod).String(),
+		DNSPolicy:  pod.Spec.DNSPolicy,
+		DNSConfig:  pod.Spec.DNSConfig,
+		Decision:   d.Outcome,
+		Reason:     d.Reason,
+		Policy:     d.Policy,
+		Rule:       d.Rule,
+		Patch:      d.Patch,
+		ResolvConf: resolvConf(pod, dnsConfig, pod.Namespace, m.clusterDomain),
+	}
+	if err != nil {
+		t.Error = err.Error()
+	}
+
+	if reason := m.namespaceFilter.rejection(pod.Namespace); reason != "" {
+		t.NamespaceFilter.Reason = "namespace " + reason
+		return t
+	}
+	t.NamespaceFilter.Mutate = true
+
+	p := m.policyFor(pod.Namespace)
+	t.NamespacePolicy = &PolicyTrace{
+		Mode:   p.checker.Mode(),
+		Ndots:  p.ndots,
+		Tenant: p.name == PolicyNamespace,
+	}
+	if d.Mutates() {
+		// The ndots expression may have computed another value.
+		t.NamespacePolicy.Ndots = d.NdotsAfter
+	}
+	if d.Reason == ReasonPolicyExemption {
+		t.NamespacePolicy.Exempt = true
+		return t
+	}
+
+	if err != nil || d.Reason == ReasonConditionNotMet {
+		return t
+	}
+
+	podLabels, annotations, owner := m.optInSource(pod.Namespace, pod, p.checker)
+	value, foundKey, found := p.checker.lookup(podLabels, annotations)
+	t.Key = &KeyTrace{
+		Key:           p.checker.Key(),
+		Source:        p.checker.Source(),
+		InheritedFrom: owner,
+		Found:         found,
+		FoundKey:      foundKey,
+		Value:         value,
+	}
+	return t
+}
+
+// withNdots returns a copy of cfg with the ndots option set to value.
+func withNdots(cfg *corev1.PodDNSConfig, value string) *corev1.PodDNSConfig {
+	out := &corev1.PodDNSConfig{}
+	if cfg != nil {
+		out = cfg.DeepCopy()
+	}
+	if idx := findNdotsIndex(out.Options); idx != -1 {
+		out.Options[idx].Value = &value
+		return out
+	}
+	out.Options = append(out.Options, corev1.PodDNSConfigOption{Name: "ndots", Value: &value})
+	return out
+}
+
+// resolvConf renders the resolv.conf kubelet writes for pod with dnsConfig.
+// Values only known to the node, such as the cluster DNS address and the
+// node's own resolver settings, are shown as comments.
+func resolvConf(pod *corev1.Pod, dnsConfig *corev1.PodDNSConfig, namespace, clusterDomain string) string {
+	policy := pod.Spec.DNSPolicy
+	if policy == "" {
+		policy = corev1.DNSClusterFirst
+	}
+	if policy == corev1.DNSClusterFirst && pod.Spec.HostNetwork {
+		policy = corev1.DNSDefault
+	}
+
+	var b strings.Builder
+	var searches, nameservers, options []string
+	switch policy {
+	case corev1.DNSClusterFirst, corev1.DNSClusterFirstWithHostNet:
+		searches = []string{
+			namespace + ".svc." + clusterDomain,
+			"svc." + clusterDomain,
+			clusterDomain,
+		}
+		nameservers = []string{"<cluster DNS>"}
+		options = []string{fmt.Sprintf("ndots:%d", defaultClusterFirstNdots)}
+		b.WriteString("# node search domains are appended to the search line\n")
+	case corev1.DNSDefault:
+		b.WriteString("# node resolver settings, plus:\n")
+	}
+
+	if dnsConfig != nil {
+		searches = append(searches, dnsConfig.Searches...)
+		if len(dnsConfig.Nameservers) > 0 {
+			nameservers = append(nameservers, dnsConfig.Nameservers...)
+		}
+		for _, opt := range dnsConfig.Options {
+			rendered := opt.Name
+			if opt.Value != nil {
+				rendered += ":" + *opt.Value
+			}
+			options = mergeOption(options, opt.Name, rendered)
+		}
+	}
+
+	if len(searches) > 0 {
+		fmt.Fprintf(&b, "search %s\n", strings.Join(searches, " "))
+	}
+	for _, ns := range nameservers {
+		fmt.Fprintf(&b, "nameserver %s\n", ns)
+	}
+	if len(options) > 0 {
+		fmt.Fprintf(&b, "options %s\n", strings.Join(options, " "))
+	}
+	return b.String()
+}
+
+// mergeOption replaces the option called name in options with rendered, or
+// appends it, like kubelet merges dnsConfig options into its defaults.
+func mergeOption(options []string, name, rendered string) []string {
+	for i, opt := range options {
+		if opt == name || strings.HasPrefix(opt, name+":") {
+			options[i] = rendered
+			return options
+		}
+	}
+	return append(options, rendered)
+}
+
+// ExplainHandler serves Mutator.Explain over HTTP. The body is a Pod or an
+// AdmissionReview of a Pod; a Pod without a namespace is evaluated in the
+// namespace query parameter. Nothing is recorded, so it is safe to expose
+// to application teams.
+type ExplainHandler struct {
+	mutator  *Mutator
+	failures FailurePolicy
+}
+
+// NewExplainHandler creates a handler explaining the decisions of mutator.
+func NewExplainHandler(mutator *Mutator) *ExplainHandler {
+	return &ExplainHandler{mutator: mutator}
+}
+
+// SetFailurePolicy reports with the FailureMode of p how admission answers
+// pods it fails on. It should match the admission Handler's policy.
+func (e *ExplainHandler) SetFailurePolicy(p FailurePolicy) {
+	e.failures = p
+}
+
+func (e *ExplainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, readErr := readBody(w, r)
+	if readErr != nil {
+		http.Error(w, readErr.Error(), readErr.Code.HTTPStatus())
+		return
+	}
+	if len(body) == 0 {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	pod, err := explainedPod(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = r.URL.Query().Get("namespace")
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = "default"
+	}
+
+	trace := e.mutator.Explain(r.Context(), pod)
+	trace.FailureMode = e.failures.Mode(pod.Namespace)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(trace)
+}
+
+// explainedPod decodes a Pod or the Pod in an AdmissionReview.
+func explainedPod(body []byte) (*corev1.Pod, error) {
+	var object struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, fmt.Errorf("failed to decode body: %w", err)
+	}
+
+	pod := &corev1.Pod{}
+	switch object.Kind {
+	case "Pod":
+		if err := json.Unmarshal(body, pod); err != nil {
+			return nil, fmt.Errorf("failed to decode pod: %w", err)
+		}
+	case "AdmissionReview":
+		var review admissionv1.AdmissionReview
+		if err := json.Unmarshal(body, &review); err != nil {
+			return nil, fmt.Errorf("failed to decode admission review: %w", err)
+		}
+		if review.Request == nil || review.Request.Kind.Kind != "Pod" {
+			return nil, fmt.Errorf("admission review does not contain a pod")
+		}
+		if err := json.Unmarshal(review.Request.Object.Raw, pod); err != nil {
+			return nil, fmt.Errorf("failed to decode pod: %w", err)
+		}
+		if pod.Namespace == "" {
+			pod.Namespace = review.Request.Namespace
+		}
+	default:
+		return nil, fmt.Errorf("expected a Pod or an AdmissionReview, got kind %q", object.Kind)
+	}
+	return pod, nil
+}
@@ -0,0 +1,225 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestMutator_Explain(t *testing.T) {
+	cfg := &config.Config{
+		NdotsValue:       2,
+		AnnotationKey:    "change-ndots",
+		AnnotationMode:   "opt-out",
+		NamespaceExclude: []string{"kube-system"},
+	}
+	three := "3"
+
+	t.Run("namespace excluded", func(t *testing.T) {
+		m := NewMutator(cfg, slog.Default())
+		trace := m.Explain(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dns", Namespace: "kube-system"}})
+
+		assert.False(t, trace.NamespaceFilter.Mutate)
+		assert.Equal(t, "namespace excluded", trace.NamespaceFilter.Reason)
+		assert.Nil(t, trace.NamespacePolicy)
+		assert.Nil(t, trace.Key)
+		assert.Equal(t, OutcomeSkip, trace.Decision)
+		assert.Equal(t, ReasonNamespaceExcluded, trace.Reason)
+		assert.Contains(t, trace.ResolvConf, "options ndots:5\n")
+	})
+
+	t.Run("tenant exemption", func(t *testing.T) {
+		m := NewMutator(cfg, slog.Default())
+		m.SetPolicySource(stubPolicySource{
+			"shop": {Exempt: labels.SelectorFromSet(labels.Set{"app": "legacy"})},
+		})
+		trace := m.Explain(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: "legacy", Namespace: "shop", Labels: map[string]string{"app": "legacy"},
+		}})
+
+		require.NotNil(t, trace.NamespacePolicy)
+		assert.True(t, trace.NamespacePolicy.Tenant)
+		assert.True(t, trace.NamespacePolicy.Exempt)
+		assert.Nil(t, trace.Key)
+		assert.Equal(t, ReasonPolicyExemption, trace.Reason)
+		assert.Equal(t, PolicyNamespace, trace.Policy)
+	})
+
+	t.Run("opted out via owner", func(t *testing.T) {
+		m := NewMutator(cfg, slog.Default())
+		m.SetOwnerLookup(&stubOwnerLookup{chain: []metav1.Object{
+			&metav1.ObjectMeta{Name: "web", Annotations: map[string]string{"change-ndots": "false"}},
+		}})
+		trace := m.Explain(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop"}})
+
+		require.NotNil(t, trace.Key)
+		assert.Equal(t, KeyTrace{
+			Key: "change-ndots", Source: FromAnnotation, InheritedFrom: "web",
+			Found: true, FoundKey: "change-ndots", Value: "false",
+		}, *trace.Key)
+		assert.Equal(t, ReasonOptedOut, trace.Reason)
+		assert.Equal(t, `change-ndots="false" on owner web`, trace.Rule)
+		assert.Empty(t, trace.Patch)
+	})
+
+	t.Run("mutated", func(t *testing.T) {
+		m := NewMutator(cfg, slog.Default())
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Searches: []string{"example.com"},
+				Options:  []corev1.PodDNSConfigOption{{Name: "ndots", Value: &three}, {Name: "edns0"}},
+			}},
+		}
+		trace := m.Explain(context.Background(), pod)
+
+		assert.Equal(t, PolicyTrace{Mode: ModeOptOut, Ndots: 2}, *trace.NamespacePolicy)
+		assert.False(t, trace.Key.Found)
+		assert.Equal(t, OutcomeMutate, trace.Decision)
+		assert.Equal(t, ReasonNotOptedOut, trace.Reason)
+		assert.Equal(t, PolicyCluster, trace.Policy)
+		assert.Equal(t, []PatchOperation{{Op: "replace", Path: "/spec/dnsConfig/options/0/value", Value: "2"}}, trace.Patch)
+		assert.Equal(t, "# node search domains are appended to the search line\n"+
+			"search shop.svc.cluster.local svc.cluster.local cluster.local example.com\n"+
+			"nameserver <cluster DNS>\n"+
+			"options ndots:2 edns0\n", trace.ResolvConf)
+		assert.Equal(t, "3", *pod.Spec.DNSConfig.Options[0].Value, "pod must not be modified")
+	})
+
+	t.Run("already compliant", func(t *testing.T) {
+		m := NewMutator(&config.Config{NdotsValue: 3, AnnotationKey: "change-ndots", AnnotationMode: "always"}, slog.Default())
+		trace := m.Explain(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: corev1.PodSpec{
+				DNSPolicy: corev1.DNSNone,
+				DNSConfig: &corev1.PodDNSConfig{
+					Nameservers: []string{"1.1.1.1"},
+					Options:     []corev1.PodDNSConfigOption{{Name: "ndots", Value: &three}},
+				},
+			},
+		})
+
+		assert.Equal(t, ReasonAlreadyCompliant, trace.Reason)
+		assert.Equal(t, "nameserver 1.1.1.1\noptions ndots:3\n", trace.ResolvConf)
+	})
+
+	t.Run("expressions are not recorded", func(t *testing.T) {
+		exprCfg := *cfg
+		exprCfg.MutationCondition = "true"
+		exprCfg.NdotsExpression = "3"
+		exprCfg.ExpressionCostLimit = 1000
+		m := NewMutator(&exprCfg, slog.Default())
+		counts := expressionCounts{}
+		m.SetExpressionMetrics(counts)
+		trace := m.Explain(context.Background(), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}})
+
+		assert.Equal(t, OutcomeMutate, trace.Decision)
+		assert.Equal(t, []PatchOperation{{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{
+			"options": []interface{}{map[string]interface{}{"name": "ndots", "value": "3"}},
+		}}}, trace.Patch)
+		assert.Empty(t, counts)
+	})
+
+	t.Run("nothing is logged", func(t *testing.T) {
+		rewriteCfg := *cfg
+		rewriteCfg.FQDNRewriteNamespaces = []string{"shop"}
+		var logs bytes.Buffer
+		m := NewMutator(&rewriteCfg, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		trace := m.Explain(context.Background(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "app",
+				Env:  []corev1.EnvVar{{Name: "ORDERS_URL", Value: "http://orders.shop:8080"}},
+			}}},
+		})
+
+		assert.Contains(t, trace.Patch, PatchOperation{
+			Op: "replace", Path: "/spec/containers/0/env/0/value", Value: "http://orders.shop.svc.cluster.local.:8080",
+		})
+		assert.Empty(t, logs.String())
+	})
+}
+
+func TestExplainHandler(t *testing.T) {
+	m := NewMutator(&config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}, slog.Default())
+	handler := NewExplainHandler(m)
+
+	explain := func(t *testing.T, target, body string) (*httptest.ResponseRecorder, Trace) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", target, bytes.NewBufferString(body)))
+		var trace Trace
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trace))
+		}
+		return w, trace
+	}
+
+	t.Run("pod", func(t *testing.T) {
+		w, trace := explain(t, "/explain?namespace=shop", `{"kind":"Pod","metadata":{"name":"web"}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "shop", trace.Namespace)
+		assert.Equal(t, OutcomeMutate, trace.Decision)
+	})
+
+	t.Run("admission review", func(t *testing.T) {
+		review := createValidAdmissionReview("web", "payments")
+		review.Kind = "AdmissionReview"
+		review.Request.Object.Raw = []byte(`{"metadata":{"name":"web","annotations":{"change-ndots":"false"}}}`)
+		body, err := json.Marshal(review)
+		require.NoError(t, err)
+
+		w, trace := explain(t, "/explain", string(body))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "payments", trace.Namespace)
+		assert.Equal(t, OutcomeSkip, trace.Decision)
+	})
+
+	t.Run("other kind", func(t *testing.T) {
+		w, _ := explain(t, "/explain", `{"kind":"Deployment"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("failure mode", func(t *testing.T) {
+		m := NewMutator(&config.Config{
+			NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out",
+			NdotsExpression: "16", ExpressionCostLimit: 1000,
+		}, slog.Default())
+		handler := NewExplainHandler(m)
+		handler.SetFailurePolicy(FailurePolicy{Namespaces: map[string]FailureMode{"payments": FailureDeny}})
+
+		for namespace, want := range map[string]FailureMode{"shop": FailureAllow, "payments": FailureDeny} {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("POST", "/explain?namespace="+namespace, bytes.NewBufferString(`{"kind":"Pod","metadata":{"name":"web"}}`)))
+			require.Equal(t, http.StatusOK, w.Code)
+			var trace Trace
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trace))
+			assert.Equal(t, "evaluate ndots expression: result 16 is not between 0 and 15", trace.Error)
+			assert.Equal(t, want, trace.FailureMode, namespace)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		body := `{"kind":"Pod","metadata":{"name":"web","annotations":{"padding":"` + strings.Repeat("x", maxRequestBody) + `"}}}`
+		w, _ := explain(t, "/explain", body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/explain", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
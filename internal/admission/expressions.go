@@ -0,0 +1,112 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/expression"
+)
+
+// Names of the policy expressions, used as the metrics label.
+const (
+	ExpressionCondition = "condition"
+	ExpressionNdots     = "ndots"
+)
+
+// SetNamespaceLookup makes namespaces available to expressions as
+// namespaceObject. Without it namespaceObject is null.
+func (m *Mutator) SetNamespaceLookup(namespaces NamespaceLookup) {
+	m.namespaces = namespaces
+}
+
+// SetExpressionMetrics records the outcome and duration of each expression
+// evaluation at admission to recorder. Evaluations for compliance checks,
+// policy reports and explanations are not recorded.
+func (m *Mutator) SetExpressionMetrics(recorder ExpressionRecorder) {
+	m.exprMetrics = recorder
+}
+
+// expressionVars returns the variables for evaluating the policy expressions
+// on pod for req.
+func (m *Mutator) expressionVars(req Request, pod *corev1.Pod) (expression.Vars, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		return expression.Vars{}, fmt.Errorf("convert pod: %w", err)
+	}
+	vars := expression.Vars{
+		Object:  object,
+		Request: requestObject(req),
+	}
+	if m.namespaces != nil {
+		if ns, ok := m.namespaces.Namespace(req.Namespace); ok {
+			if vars.NamespaceObject, err = runtime.DefaultUnstructuredConverter.ToUnstructured(ns); err != nil {
+				return expression.Vars{}, fmt.Errorf("convert namespace: %w", err)
+			}
+		}
+	}
+	return vars, nil
+}
+
+// requestObject returns req in the JSON form of an AdmissionRequest.
+func requestObject(req Request) map[string]interface{} {
+	groups := make([]interface{}, len(req.UserInfo.Groups))
+	for i, g := range req.UserInfo.Groups {
+		groups[i] = g
+	}
+	return map[string]interface{}{
+		"uid":       string(req.UID),
+		"operation": string(req.Operation),
+		"namespace": req.Namespace,
+		"userInfo": map[string]interface{}{
+			"username": req.UserInfo.Username,
+			"uid":      req.UserInfo.UID,
+			"groups":   groups,
+		},
+		"dryRun": req.DryRun,
+	}
+}
+
+// evalCondition reports whether the mutation condition holds, recording the
+// evaluation to recorder if it is not nil. It is true if no condition is
+// configured.
+func (m *Mutator) evalCondition(ctx context.Context, vars expression.Vars, recorder ExpressionRecorder) (bool, error) {
+	if m.condition == nil {
+		return true, nil
+	}
+
+	start := time.Now()
+	ok, err := m.condition.EvalBool(ctx, vars)
+	if err != nil {
+		observeExpression(recorder, ExpressionCondition, "error", start)
+		return false, fmt.Errorf("evaluate mutation condition: %w", err)
+	}
+	observeExpression(recorder, ExpressionCondition, strconv.FormatBool(ok), start)
+	return ok, nil
+}
+
+// evalNdots returns the ndots value computed by the ndots expression,
+// recording the evaluation to recorder if it is not nil.
+func (m *Mutator) evalNdots(ctx context.Context, vars expression.Vars, recorder ExpressionRecorder) (int, error) {
+	start := time.Now()
+	n, err := m.ndotsExpr.EvalInt(ctx, vars)
+	if err == nil && (n < 0 || n > 15) {
+		err = fmt.Errorf("result %d is not between 0 and 15", n)
+	}
+	if err != nil {
+		observeExpression(recorder, ExpressionNdots, "error", start)
+		return 0, fmt.Errorf("evaluate ndots expression: %w", err)
+	}
+	observeExpression(recorder, ExpressionNdots, "ok", start)
+	return int(n), nil
+}
+
+func observeExpression(recorder ExpressionRecorder, name, result string, start time.Time) {
+	if recorder != nil {
+		recorder.RecordExpression(name, result, time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,81 @@
+package admission
+
+import (
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// FailureMode is how the handler answers requests it fails on: when the pod
+// cannot be decoded, the mutator returns an error or the patch cannot be
+// built or verified. Failures to reach the webhook at all are covered by the
+// webhook's failurePolicy instead.
+type FailureMode string
+
+const (
+	// FailureAllow admits the pod unchanged.
+	FailureAllow FailureMode = "allow"
+	// FailureDeny rejects the pod, with the error as the reason.
+	FailureDeny FailureMode = "deny"
+)
+
+// FailurePolicy selects the FailureMode for a namespace.
+type FailurePolicy struct {
+	// Default applies to namespaces not listed in Namespaces. It is
+	// FailureAllow if empty.
+	Default    FailureMode
+	Namespaces map[string]FailureMode
+}
+
+// Mode returns the FailureMode for namespace.
+func (p FailurePolicy) Mode(namespace string) FailureMode {
+	if mode, ok := p.Namespaces[namespace]; ok {
+		return mode
+	}
+	if p.Default == "" {
+		return FailureAllow
+	}
+	return p.Default
+}
+
+// SetFailurePolicy decides with p whether pods the handler fails on are
+// admitted or denied. Without it they are admitted unchanged.
+func (h *Handler) SetFailurePolicy(p FailurePolicy) {
+	h.failures = p
+}
+
+// fail answers a request the handler failed on with err, according to the
+// FailureMode of namespace.
+func (h *Handler) fail(uid types.UID, namespace string, pod *corev1.Pod, err *Error) *admissionv1.AdmissionResponse {
+	mode := h.failures.Mode(namespace)
+	h.logger.Error("admission request failed",
+		"namespace", namespace,
+		"name", getPodName(pod),
+		"errorCode", err.Code,
+		"failureMode", mode,
+		"error", err,
+	)
+	h.recordError(err.Code)
+	h.recordDecision(uid, namespace, pod, "error", Decision{Rule: err.Error()})
+
+	if mode != FailureDeny {
+		h.recordFailure(err.Code, "allowed")
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+
+	h.recordFailure(err.Code, "denied")
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  err.Status(),
+	}
+}
+
+// recordFailure safely records the answer to a failed request if metrics is
+// configured.
+func (h *Handler) recordFailure(code ErrorCode, action string) {
+	if h.metrics != nil {
+		h.metrics.RecordFailure(code, action)
+	}
+}
@@ -0,0 +1,167 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFailurePolicy_Mode(t *testing.T) {
+	p := FailurePolicy{Default: FailureDeny, Namespaces: map[string]FailureMode{"sandbox": FailureAllow}}
+	assert.Equal(t, FailureDeny, p.Mode("payments"))
+	assert.Equal(t, FailureAllow, p.Mode("sandbox"))
+	assert.Equal(t, FailureAllow, FailurePolicy{}.Mode("payments"))
+}
+
+func TestHandler_FailureMode(t *testing.T) {
+	policy := FailurePolicy{Namespaces: map[string]FailureMode{"payments": FailureDeny}}
+	validPod := `{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}]}}`
+
+	tests := []struct {
+		name        string
+		namespace   string
+		pod         string
+		decision    Decision
+		err         error
+		errorType   ErrorCode
+		wantAllowed bool
+		wantStatus  *metav1.Status // Message is a prefix
+	}{
+		{
+			name:        "mutation error allowed",
+			namespace:   "default",
+			pod:         validPod,
+			err:         errors.New("boom"),
+			errorType:   CodePolicy,
+			wantAllowed: true,
+		},
+		{
+			name:      "mutation error denied",
+			namespace: "payments",
+			pod:       validPod,
+			err:       errors.New("boom"),
+			errorType: CodePolicy,
+			wantStatus: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "mutation failed: boom",
+				Reason:  metav1.StatusReasonInternalError,
+				Code:    http.StatusInternalServerError,
+			},
+		},
+		{
+			name:        "decode error allowed",
+			namespace:   "default",
+			pod:         `{"spec":"not a pod spec"}`,
+			errorType:   CodeDecodeObject,
+			wantAllowed: true,
+		},
+		{
+			name:      "decode error denied",
+			namespace: "payments",
+			pod:       `{"spec":"not a pod spec"}`,
+			errorType: CodeDecodeObject,
+			wantStatus: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "failed to decode pod: ",
+				Reason:  metav1.StatusReasonBadRequest,
+				Code:    http.StatusBadRequest,
+			},
+		},
+		{
+			name:      "timeout denied",
+			namespace: "payments",
+			pod:       validPod,
+			err:       fmt.Errorf("evaluate mutation condition: %w", context.DeadlineExceeded),
+			errorType: CodeTimeout,
+			wantStatus: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "request timed out: evaluate mutation condition: context deadline exceeded",
+				Reason:  metav1.StatusReasonTimeout,
+				Code:    http.StatusGatewayTimeout,
+			},
+		},
+		{
+			name:      "patch build error denied",
+			namespace: "payments",
+			pod:       validPod,
+			err:       fmt.Errorf("mutator fqdn: %w", newError(CodePatchBuild, errors.New("conflict"))),
+			errorType: CodePatchBuild,
+			wantStatus: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "failed to build patch: conflict",
+				Reason:  metav1.StatusReasonInternalError,
+				Code:    http.StatusInternalServerError,
+			},
+		},
+		{
+			name:      "verify error denied",
+			namespace: "payments",
+			pod:       validPod,
+			decision:  mutateDecision(PatchOperation{Op: "remove", Path: "/spec/dnsConfig"}),
+			errorType: CodePatchVerify,
+			wantStatus: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: "patch verification failed: apply patch: ",
+				Reason:  metav1.StatusReasonInternalError,
+				Code:    http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMutator := new(MockMutator)
+			if tt.errorType != CodeDecodeObject {
+				mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(tt.decision, tt.err)
+			}
+			action := "denied"
+			if tt.wantAllowed {
+				action = "allowed"
+			}
+			mockMetrics := new(MockMetricsRecorder)
+			mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+			mockMetrics.On("RecordError", tt.errorType).Once()
+			mockMetrics.On("RecordFailure", tt.errorType, action).Once()
+
+			h := NewHandlerWithMetrics(mockMutator, slog.Default(), mockMetrics)
+			h.SetFailurePolicy(policy)
+
+			review := createValidAdmissionReview("web", tt.namespace)
+			review.Request.Object.Raw = []byte(tt.pod)
+			body, _ := json.Marshal(review)
+			w := httptest.NewRecorder()
+			h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp admissionv1.AdmissionReview
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, tt.wantAllowed, resp.Response.Allowed)
+			assert.Empty(t, resp.Response.Patch)
+			if tt.wantStatus == nil {
+				assert.Nil(t, resp.Response.Result)
+			} else {
+				got := resp.Response.Result
+				require.NotNil(t, got)
+				assert.Equal(t, tt.wantStatus.Status, got.Status)
+				assert.Equal(t, tt.wantStatus.Reason, got.Reason)
+				assert.Equal(t, tt.wantStatus.Code, got.Code)
+				assert.True(t, strings.HasPrefix(got.Message, tt.wantStatus.Message), got.Message)
+			}
+			mockMutator.AssertExpectations(t)
+			mockMetrics.AssertExpectations(t)
+		})
+	}
+}
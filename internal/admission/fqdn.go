@@ -0,0 +1,109 @@
+package admission
+
+import (
+	"log/slog"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rewriteEnabled reports whether env values of pods in namespace are rewritten
+// to fully-qualified service names.
+func (m *Mutator) rewriteEnabled(namespace string) bool {
+	return m.fqdnRewrite[namespace]
+}
+
+// qualifyEnv rewrites the literal env values of the containers of pod in
+// namespace that reference partially qualified service names to their FQDNs.
+// Values sourced from secrets or config maps are never touched. Each rewrite
+// is logged to logger.
+func (m *Mutator) qualifyEnv(namespace string, pod *corev1.Pod, logger *slog.Logger) {
+	m.qualifyContainerEnv(pod.Spec.InitContainers, namespace, logger)
+	m.qualifyContainerEnv(pod.Spec.Containers, namespace, logger)
+}
+
+func (m *Mutator) qualifyContainerEnv(containers []corev1.Container, namespace string, logger *slog.Logger) {
+	for i := range containers {
+		c := &containers[i]
+		for j := range c.Env {
+			env := &c.Env[j]
+			if env.ValueFrom != nil || env.Value == "" {
+				continue
+			}
+			value, changed := qualifyHostnames(env.Value, namespace, m.clusterDomain)
+			if !changed {
+				continue
+			}
+			logger.Debug("rewriting env value to fully-qualified service name",
+				"namespace", namespace,
+				"container", c.Name,
+				"env", env.Name,
+			)
+			env.Value = value
+		}
+	}
+}
+
+// qualifyHostnames replaces every partially qualified service name in s with
+// its absolute FQDN, leaving the rest of the value (scheme, credentials, port,
+// path) untouched.
+func qualifyHostnames(s, namespace, clusterDomain string) (string, bool) {
+	var b strings.Builder
+	last := 0
+	for _, span := range hostnameSpans(s) {
+		fqdn := qualifyServiceName(s[span[0]:span[1]], namespace, clusterDomain)
+		if fqdn == "" {
+			continue
+		}
+		b.WriteString(s[last:span[0]])
+		b.WriteString(fqdn)
+		last = span[1]
+	}
+	if last == 0 {
+		return s, false
+	}
+	b.WriteString(s[last:])
+	return b.String(), true
+}
+
+// qualifyServiceName returns the absolute FQDN for a partially qualified
+// in-cluster name, or "" if host is not recognisably one. Accepted forms are
+// service.namespace, service.namespace.svc, pod.service.namespace.svc and any
+// of those followed by a prefix of the cluster domain. The service.namespace
+// form is only rewritten when it cannot be a public name: its last label is
+// the pod's namespace or is not a public suffix.
+func qualifyServiceName(host, namespace, clusterDomain string) string {
+	if strings.HasSuffix(host, ".") {
+		return ""
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) == 2 {
+		if !needsSearch(host, namespace, clusterDomain) {
+			return ""
+		}
+		return host + ".svc." + clusterDomain + "."
+	}
+
+	svc := -1
+	for i, label := range labels {
+		if label == "svc" {
+			svc = i
+		}
+	}
+	if svc != 2 && svc != 3 {
+		return ""
+	}
+
+	rest := labels[svc+1:]
+	domain := strings.Split(clusterDomain, ".")
+	if len(rest) >= len(domain) {
+		return ""
+	}
+	for i := range rest {
+		if rest[i] != domain[i] {
+			return ""
+		}
+	}
+	return strings.Join(labels[:svc+1], ".") + "." + clusterDomain + "."
+}
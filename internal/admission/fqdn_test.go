@@ -0,0 +1,113 @@
+package admission
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestQualifyHostnames(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		namespace   string
+		want        string
+		wantChanged bool
+	}{
+		{"url with service.namespace", "http://orders.shop:8080", "shop", "http://orders.shop.svc.cluster.local.:8080", true},
+		{"bare svc name", "db.prod.svc", "shop", "db.prod.svc.cluster.local.", true},
+		{"host and port", "redis.cache.svc:6379", "shop", "redis.cache.svc.cluster.local.:6379", true},
+		{"partial cluster domain", "api.billing.svc.cluster:443", "shop", "api.billing.svc.cluster.local.:443", true},
+		{"statefulset pod name", "kafka-0.kafka.data.svc", "shop", "kafka-0.kafka.data.svc.cluster.local.", true},
+		{"dsn keeps credentials and path", "postgres://app:pw@db.prod.svc:5432/app?sslmode=disable", "shop",
+			"postgres://app:pw@db.prod.svc.cluster.local.:5432/app?sslmode=disable", true},
+		{"broker list", "kafka-0.kafka.svc:9092,kafka-1.kafka.svc:9092", "shop",
+			"kafka-0.kafka.svc.cluster.local.:9092,kafka-1.kafka.svc.cluster.local.:9092", true},
+		{"unknown tld service.namespace", "ledger.payments", "shop", "ledger.payments.svc.cluster.local.", true},
+		{"public name kept", "https://api.github.com", "shop", "https://api.github.com", false},
+		{"public looking name in other namespace kept", "orders.shop", "web", "orders.shop", false},
+		{"already fully qualified", "orders.shop.svc.cluster.local", "shop", "orders.shop.svc.cluster.local", false},
+		{"absolute name kept", "orders.shop.svc.cluster.local.", "shop", "orders.shop.svc.cluster.local.", false},
+		{"ip address kept", "10.0.0.1:8080", "shop", "10.0.0.1:8080", false},
+		{"file path kept", "/etc/orders.shop/config.yaml", "shop", "/etc/orders.shop/config.yaml", false},
+		{"malformed url kept", "http://orders.shop:port", "shop", "http://orders.shop:port", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := qualifyHostnames(tt.value, tt.namespace, "cluster.local")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantChanged, changed)
+		})
+	}
+}
+
+func TestMutator_Mutate_FQDNRewrite(t *testing.T) {
+	cfg := &config.Config{
+		NdotsValue:            2,
+		ClusterDomain:         "cluster.local",
+		FQDNRewriteNamespaces: []string{"shop"},
+	}
+	mutator := NewMutator(cfg, slog.Default())
+
+	newPod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{
+					Name: "migrate",
+					Env:  []corev1.EnvVar{{Name: "DB_URL", Value: "postgres://db.prod.svc:5432/orders"}},
+				}},
+				Containers: []corev1.Container{{
+					Name: "app",
+					Env: []corev1.EnvVar{
+						{Name: "LOG_LEVEL", Value: "info"},
+						{Name: "ORDERS_URL", Value: "http://orders.shop:8080"},
+						{Name: "SECRET_URL", ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{Key: "url"},
+						}},
+					},
+				}},
+			},
+		}
+	}
+
+	t.Run("enabled namespace", func(t *testing.T) {
+		decision, err := mutator.Mutate(context.Background(), Request{Namespace: "shop"}, newPod("shop"))
+		require.NoError(t, err)
+		patches := decision.Patch
+		require.Len(t, patches, 3)
+
+		assert.Equal(t, PatchOperation{
+			Op:    "replace",
+			Path:  "/spec/containers/0/env/1/value",
+			Value: "http://orders.shop.svc.cluster.local.:8080",
+		}, patches[0])
+		assert.Equal(t, "/spec/dnsConfig", patches[1].Path)
+		assert.Equal(t, PatchOperation{
+			Op:    "replace",
+			Path:  "/spec/initContainers/0/env/0/value",
+			Value: "postgres://db.prod.svc.cluster.local.:5432/orders",
+		}, patches[2])
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		decision, err := mutator.Mutate(context.Background(), Request{Namespace: "web"}, newPod("web"))
+		require.NoError(t, err)
+		patches := decision.Patch
+		require.Len(t, patches, 1)
+		assert.Equal(t, "/spec/dnsConfig", patches[0].Path)
+	})
+
+	t.Run("rewritten values are not linted", func(t *testing.T) {
+		assert.Empty(t, mutator.Lint("shop", newPod("shop"), 2))
+		assert.NotEmpty(t, mutator.Lint("web", newPod("web"), 2))
+	})
+}
@@ -1,11 +1,17 @@
 package admission
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
@@ -13,12 +19,21 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type Handler struct {
-	mutator PodMutator
-	logger  *slog.Logger
-	metrics MetricsRecorder
+	mutator  PodMutator
+	logger   *slog.Logger
+	metrics  MetricsRecorder
+	schedule *Schedule
+	shadow   *Shadow
+	events   PodEventRecorder
+	ring     *DecisionRing
+	cache    *DecisionCache
+
+	testOperations bool
+	failures       FailurePolicy
 }
 
 func NewHandler(mutator PodMutator, logger *slog.Logger) *Handler {
@@ -37,6 +52,29 @@ func NewHandlerWithMetrics(mutator PodMutator, logger *slog.Logger, metrics Metr
 	}
 }
 
+// SetSchedule phases in enforcement according to s. Without a schedule the
+// handler always enforces.
+func (h *Handler) SetSchedule(s *Schedule) {
+	h.schedule = s
+}
+
+// SetShadow evaluates a candidate policy in shadow for every pod. Only the
+// active mutator affects responses.
+func (h *Handler) SetShadow(s *Shadow) {
+	h.shadow = s
+}
+
+// SetEvents records Kubernetes Events about mutated and deferred pods, and
+// about pods with an invalid opt-in/opt-out value, with r.
+func (h *Handler) SetEvents(r PodEventRecorder) {
+	h.events = r
+}
+
+// SetDecisionRing keeps every admission decision in ring.
+func (h *Handler) SetDecisionRing(ring *DecisionRing) {
+	h.ring = ring
+}
+
 var (
 	scheme       = runtime.NewScheme()
 	codecs       = serializer.NewCodecFactory(scheme)
@@ -52,71 +90,119 @@ func (h *Handler) HandleMutate(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	body, err := io.ReadAll(r.Body)
+	// The API server always sends JSON; requests without a content type are
+	// accepted for clients such as curl.
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "application/json" {
+			h.httpError(w, newError(CodeContentType, fmt.Errorf("got %q, want application/json", ct)))
+			return
+		}
+	}
+
+	body, err := readBody(w, r)
 	if err != nil {
-		h.logger.Error("failed to read body", "error", err)
-		h.recordError("read")
-		http.Error(w, "failed to read body", http.StatusBadRequest)
+		h.httpError(w, err)
 		return
 	}
-
 	if len(body) == 0 {
-		h.logger.Error("empty body")
-		h.recordError("read")
-		http.Error(w, "empty body", http.StatusBadRequest)
+		h.httpError(w, newError(CodeRead, errors.New("empty body")))
 		return
 	}
 
-	// Verify content type? Usually application/json.
-	// For now, assume json/yaml parsable by deserializer.
-
 	var admissionReview admissionv1.AdmissionReview
 	if _, _, err := deserializer.Decode(body, nil, &admissionReview); err != nil {
-		h.logger.Error("failed to decode admission review", "error", err)
-		h.recordError("decode")
-		http.Error(w, "failed to decode admission review", http.StatusBadRequest)
+		h.httpError(w, newError(CodeDecodeReview, err))
 		return
 	}
-
 	if admissionReview.Request == nil {
-		h.logger.Error("admission review request is nil")
-		h.recordError("decode")
-		http.Error(w, "admission review request is nil", http.StatusBadRequest)
+		h.httpError(w, newError(CodeDecodeReview, errors.New("admission review request is nil")))
 		return
 	}
 
-	response := h.mutate(admissionReview.Request)
-	admissionReview.Response = response
-	admissionReview.Response.UID = admissionReview.Request.UID
+	response := h.mutate(r.Context(), admissionReview.Request)
+	response.UID = admissionReview.Request.UID
+	h.writeResponse(w, response)
+}
+
+// maxRequestBody bounds the request bodies read by the handlers. The
+// AdmissionReview of the largest pod etcd stores (1.5 MiB) fits well within
+// it.
+const maxRequestBody = 3 << 20
 
-	respBytes, err := json.Marshal(admissionReview)
+// readBody reads the body of r, up to maxRequestBody bytes.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, *Error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
 	if err != nil {
-		h.logger.Error("failed to marshal response", "error", err)
-		h.recordError("marshal")
-		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, newError(CodeTooLarge, err)
+		}
+		return nil, newError(CodeRead, err)
+	}
+	return body, nil
+}
+
+// maxPooledBuffer is the capacity above which response buffers are dropped
+// instead of returned to the pool, so one large response does not pin its
+// memory.
+const maxPooledBuffer = 64 << 10
+
+var responseBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// writeResponse writes an AdmissionReview carrying only response. The API
+// server does not need the request echoed back, and it holds the whole pod.
+func (h *Handler) writeResponse(w http.ResponseWriter, response *admissionv1.AdmissionResponse) {
+	buf := responseBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			responseBuffers.Put(buf)
+		}
+	}()
+
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Response: response,
+	}
+	if err := json.NewEncoder(buf).Encode(review); err != nil {
+		h.httpError(w, newError(CodeMarshal, fmt.Errorf("response: %w", err)))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(respBytes)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, _ = w.Write(buf.Bytes())
+}
+
+// httpError answers a request that could not be handled as an admission
+// review with the HTTP status of err.
+func (h *Handler) httpError(w http.ResponseWriter, err *Error) {
+	h.logger.Error("admission request failed", "errorCode", err.Code, "error", err)
+	h.recordError(err.Code)
+	http.Error(w, err.Error(), err.Code.HTTPStatus())
 }
 
 // recordError safely records an error if metrics is configured.
-func (h *Handler) recordError(errorType string) {
+func (h *Handler) recordError(code ErrorCode) {
 	if h.metrics != nil {
-		h.metrics.RecordError(errorType)
+		h.metrics.RecordError(code)
 	}
 }
 
 // recordMutation safely records a mutation if metrics is configured.
-func (h *Handler) recordMutation(namespace, action string) {
+func (h *Handler) recordMutation(namespace string, workload Workload, action string, reason ReasonCode) {
 	if h.metrics != nil {
-		h.metrics.RecordMutation(namespace, action)
+		h.metrics.RecordMutation(namespace, workload, action, reason)
 	}
 }
 
 // Internal helper for logic
-func (h *Handler) mutate(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+func (h *Handler) mutate(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
 	// Only handle Pod resources
 	if req.Kind.Kind != "Pod" {
 		return &admissionv1.AdmissionResponse{
@@ -124,68 +210,235 @@ func (h *Handler) mutate(req *admissionv1.AdmissionRequest) *admissionv1.Admissi
 		}
 	}
 
+	if resp := h.prefilter(ctx, req); resp != nil {
+		return resp
+	}
+
 	var pod corev1.Pod
 	if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
-		h.recordError("decode")
-		return &admissionv1.AdmissionResponse{
-			Allowed: false,
-			Result: &metav1.Status{
-				Message: fmt.Sprintf("failed to decode pod: %v", err),
-			},
-		}
+		return h.fail(req.UID, req.Namespace, &pod, newError(CodeDecodeObject, err))
 	}
 
-	namespace := req.Namespace
-	if namespace == "" {
-		namespace = pod.Namespace
-	}
+	r := NewRequest(req, &pod)
+	namespace := r.Namespace
 
-	patch, err := h.mutator.Mutate(&pod)
+	decision, err := h.decide(ctx, r, &pod)
 	if err != nil {
-		h.logger.Error("mutation failed", "error", err)
-		h.recordError("mutation")
-		// Fail open or closed? Plan said fail open usually, but let's allow it with error log
-		return &admissionv1.AdmissionResponse{
-			Allowed: true,
+		return h.fail(req.UID, namespace, &pod, asError(err, CodePolicy))
+	}
+
+	if h.shadow != nil {
+		result := h.shadow.Evaluate(ctx, r, &pod, decision.Patch)
+		if h.metrics != nil {
+			h.metrics.RecordShadowResult(namespace, result)
 		}
 	}
 
 	podName := getPodName(&pod)
+	workload := WorkloadOf(&pod)
+	keyWarnings := h.deprecatedKeyWarnings(namespace, &pod, decision)
 
-	if patch == nil {
-		h.logger.Info("skipped mutation",
-			"namespace", namespace,
-			"name", podName,
-			"reason", "no changes needed",
-		)
-		h.recordMutation(namespace, "skipped")
-		return &admissionv1.AdmissionResponse{
-			Allowed: true,
-		}
+	if !decision.Mutates() {
+		h.invalidKeyEvent(r, &pod, decision)
+		return h.skip(req.UID, namespace, &pod, decision, keyWarnings)
 	}
 
+	if phase := h.phase(); phase != PhaseEnforce {
+		h.recordDecision(req.UID, namespace, &pod, string(phase), decision)
+		return h.deferMutation(phase, r, &pod, decision, keyWarnings)
+	}
+	h.invalidKeyEvent(r, &pod, decision)
+
+	patch := decision.Patch
+	if h.testOperations {
+		if patch, err = testOperations(req.Object.Raw, patch); err != nil {
+			return h.fail(req.UID, namespace, &pod, newError(CodePatchBuild, err))
+		}
+	}
 	patchBytes, err := json.Marshal(patch)
 	if err != nil {
-		h.logger.Error("failed to marshal patch", "error", err)
-		h.recordError("marshal")
-		return &admissionv1.AdmissionResponse{
-			Allowed: true,
-		}
+		return h.fail(req.UID, namespace, &pod, newError(CodeMarshal, fmt.Errorf("patch: %w", err)))
+	}
+	if err := verifyPatch(req.Object.Raw, patchBytes, &pod, decision); err != nil {
+		return h.fail(req.UID, namespace, &pod, newError(CodePatchVerify, err))
 	}
 
+	warnings := append(keyWarnings, h.hostnameWarnings(namespace, &pod, decision.NdotsAfter)...)
+
 	h.logger.Info("mutated pod",
 		"namespace", namespace,
 		"name", podName,
+		"workload", workload.String(),
+		"reason", decision.Reason,
+		"policy", decision.Policy,
+		"rule", decision.Rule,
 		"patch", patch,
+		"warnings", len(warnings),
 	)
-	h.recordMutation(namespace, "mutated")
+	h.recordMutation(namespace, workload, "mutated", decision.Reason)
+	h.recordDecision(req.UID, namespace, &pod, "mutated", decision)
+	h.event(r, &pod, corev1.EventTypeNormal, ReasonMutated, describePatch(patch))
 
 	patchType := admissionv1.PatchTypeJSONPatch
 	return &admissionv1.AdmissionResponse{
 		Allowed:   true,
 		Patch:     patchBytes,
 		PatchType: &patchType,
+		Warnings:  warnings,
+	}
+}
+
+// skip admits pod unchanged with warnings.
+func (h *Handler) skip(uid types.UID, namespace string, pod *corev1.Pod, decision Decision, warnings []string) *admissionv1.AdmissionResponse {
+	workload := WorkloadOf(pod)
+	h.logger.Info("skipped mutation",
+		"namespace", namespace,
+		"name", getPodName(pod),
+		"workload", workload.String(),
+		"reason", decision.Reason,
+		"policy", decision.Policy,
+		"rule", decision.Rule,
+	)
+	h.recordMutation(namespace, workload, "skipped", decision.Reason)
+	h.recordDecision(uid, namespace, pod, "skipped", decision)
+	return &admissionv1.AdmissionResponse{
+		Allowed:  true,
+		Warnings: warnings,
+	}
+}
+
+// recordDecision adds the outcome of a request to the decision ring, if
+// configured. action is the metrics action or "error"; for errors d only
+// carries the error message as its rule.
+func (h *Handler) recordDecision(uid types.UID, namespace string, pod *corev1.Pod, action string, d Decision) {
+	if h.ring == nil {
+		return
+	}
+
+	before := currentNdots(pod)
+	after := before
+	if action == "mutated" {
+		after = d.NdotsAfter
+	}
+	h.ring.Add(DecisionRecord{
+		Time:        time.Now(),
+		UID:         uid,
+		Namespace:   namespace,
+		Name:        getPodName(pod),
+		Workload:    WorkloadOf(pod).String(),
+		Decision:    action,
+		Reason:      string(d.Reason),
+		Rule:        d.Rule,
+		NdotsBefore: before,
+		NdotsAfter:  after,
+		Steps:       d.Steps,
+	})
+}
+
+// phase returns the enforcement phase in effect for the current request.
+func (h *Handler) phase() Phase {
+	if h.schedule == nil {
+		return PhaseEnforce
+	}
+	return h.schedule.Current()
+}
+
+// deferMutation admits the pod unchanged before enforcement starts. In the
+// audit phase the mutation is only logged and counted. In the warn phase the
+// response also announces the upcoming change and carries warnings, and
+// Events are recorded.
+func (h *Handler) deferMutation(phase Phase, req Request, pod *corev1.Pod, decision Decision, warnings []string) *admissionv1.AdmissionResponse {
+	namespace := req.Namespace
+	enforceFrom := h.schedule.EnforceFrom().UTC().Format(time.RFC3339)
+	workload := WorkloadOf(pod)
+
+	h.logger.Info("deferred mutation",
+		"namespace", namespace,
+		"name", getPodName(pod),
+		"workload", workload.String(),
+		"phase", phase,
+		"enforceFrom", enforceFrom,
+		"reason", decision.Reason,
+		"patch", decision.Patch,
+	)
+	h.recordMutation(namespace, workload, string(phase), decision.Reason)
+	if phase != PhaseWarn {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	h.invalidKeyEvent(req, pod, decision)
+	h.event(req, pod, corev1.EventTypeNormal, ReasonDeferred,
+		fmt.Sprintf("not mutated before %s (%s phase), would have: %s", enforceFrom, phase, describePatch(decision.Patch)))
+	warnings = append(warnings,
+		fmt.Sprintf("ndots will be enforced from %s; this pod's DNS config will then be mutated", enforceFrom))
+	warnings = append(warnings, h.hostnameWarnings(namespace, pod, decision.NdotsAfter)...)
+
+	return &admissionv1.AdmissionResponse{
+		Allowed:  true,
+		Warnings: warnings,
+	}
+}
+
+// deprecatedKeyWarnings returns an admission warning if the pod's decision is
+// read from a deprecated alias of the opt-in/opt-out key.
+func (h *Handler) deprecatedKeyWarnings(namespace string, pod *corev1.Pod, decision Decision) []string {
+	alias, key := decision.KeyIssues.DeprecatedAlias, decision.KeyIssues.Key
+	if alias == "" {
+		return nil
+	}
+
+	h.logger.Debug("deprecated opt-in/opt-out key used",
+		"namespace", namespace,
+		"name", getPodName(pod),
+		"workload", WorkloadOf(pod).String(),
+		"key", alias,
+	)
+	if h.metrics != nil {
+		h.metrics.RecordDeprecatedKey(namespace, alias)
+	}
+	return []string{fmt.Sprintf("ndots key %q is deprecated, use %q instead", alias, key)}
+}
+
+// maxHostnameWarnings caps the admission warnings returned for one pod; the
+// API server truncates long warning lists anyway.
+const maxHostnameWarnings = 5
+
+// hostnameWarnings returns admission warnings for hostnames referenced by the
+// pod that resolve slower with the ndots value the pod is mutated to.
+func (h *Handler) hostnameWarnings(namespace string, pod *corev1.Pod, ndots int) []string {
+	linter, ok := h.mutator.(PodLinter)
+	if !ok {
+		return nil
+	}
+
+	var warnings []string
+	for _, f := range linter.Lint(namespace, pod, ndots) {
+		if f.Resolution != ResolutionSlower {
+			continue
+		}
+		h.logger.Debug("hostname resolves slower with new ndots",
+			"namespace", namespace,
+			"container", f.Container,
+			"source", f.Source,
+			"field", f.Field,
+			"hostname", f.Hostname,
+		)
+		warnings = append(warnings, f.Warning())
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	if h.metrics != nil {
+		h.metrics.RecordHostnameWarnings(namespace, len(warnings))
+	}
+
+	if len(warnings) > maxHostnameWarnings {
+		more := len(warnings) - maxHostnameWarnings
+		warnings = append(warnings[:maxHostnameWarnings],
+			fmt.Sprintf("%d more hostname(s) resolve slower with the new ndots value", more))
 	}
+	return warnings
 }
 
 func getPodName(pod *corev1.Pod) string {
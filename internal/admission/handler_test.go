@@ -2,11 +2,14 @@ package admission
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,6 +18,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
 )
 
 // MockMutator is a mock implementation of PodMutator
@@ -22,31 +27,59 @@ type MockMutator struct {
 	mock.Mock
 }
 
-func (m *MockMutator) Mutate(pod *corev1.Pod) ([]PatchOperation, error) {
+func (m *MockMutator) Mutate(_ context.Context, _ Request, pod *corev1.Pod) (Decision, error) {
 	args := m.Called(pod)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).([]PatchOperation), args.Error(1)
+	return args.Get(0).(Decision), args.Error(1)
+}
+
+// mutateDecision is the Decision of a mock mutator to apply patch.
+func mutateDecision(patch ...PatchOperation) Decision {
+	return Decision{Outcome: OutcomeMutate, Reason: ReasonAlways, Patch: patch}
 }
 
+// optedOut is the Decision of a mock mutator to skip a pod.
+var optedOut = Decision{Outcome: OutcomeSkip, Reason: ReasonOptedOut}
+
 // MockMetricsRecorder is a mock implementation of MetricsRecorder
 type MockMetricsRecorder struct {
 	mock.Mock
 }
 
-func (m *MockMetricsRecorder) RecordMutation(namespace, action string) {
-	m.Called(namespace, action)
+func (m *MockMetricsRecorder) RecordMutation(namespace string, workload Workload, action string, reason ReasonCode) {
+	m.Called(namespace, workload, action, reason)
+}
+
+// testPodWorkload is the workload of the pod in createValidAdmissionReview.
+var testPodWorkload = Workload{Kind: "Pod", Name: "test-pod"}
+
+func (m *MockMetricsRecorder) RecordError(code ErrorCode) {
+	m.Called(code)
 }
 
-func (m *MockMetricsRecorder) RecordError(errorType string) {
-	m.Called(errorType)
+func (m *MockMetricsRecorder) RecordFailure(code ErrorCode, action string) {
+	m.Called(code, action)
 }
 
 func (m *MockMetricsRecorder) ObserveRequestDuration(seconds float64) {
 	m.Called(seconds)
 }
 
+func (m *MockMetricsRecorder) RecordHostnameWarnings(namespace string, count int) {
+	m.Called(namespace, count)
+}
+
+func (m *MockMetricsRecorder) RecordShadowResult(namespace, result string) {
+	m.Called(namespace, result)
+}
+
+func (m *MockMetricsRecorder) RecordDeprecatedKey(namespace, key string) {
+	m.Called(namespace, key)
+}
+
+func (m *MockMetricsRecorder) RecordDecisionCache(result string) {
+	m.Called(result)
+}
+
 func TestHandler_HandleMutate(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -79,7 +112,7 @@ func TestHandler_HandleMutate(t *testing.T) {
 			},
 			setupMock: func(m *MockMutator) {
 				m.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
-					[]PatchOperation{{Op: "add", Path: "/foo", Value: "bar"}},
+					mutateDecision(PatchOperation{Op: "add", Path: "/foo", Value: "bar"}),
 					nil,
 				)
 			},
@@ -156,13 +189,13 @@ func TestHandler_HandleMutateWithMetrics(t *testing.T) {
 			requestBody: createValidAdmissionReview("test-pod", "default"),
 			setupMutator: func(m *MockMutator) {
 				m.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
-					[]PatchOperation{{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}}},
+					mutateDecision(PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}}),
 					nil,
 				)
 			},
 			setupMetrics: func(m *MockMetricsRecorder) {
 				m.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
-				m.On("RecordMutation", "default", "mutated").Once()
+				m.On("RecordMutation", "default", testPodWorkload, "mutated", ReasonAlways).Once()
 			},
 			wantStatusCode: http.StatusOK,
 		},
@@ -170,11 +203,11 @@ func TestHandler_HandleMutateWithMetrics(t *testing.T) {
 			name:        "skipped mutation records skipped metric",
 			requestBody: createValidAdmissionReview("test-pod", "default"),
 			setupMutator: func(m *MockMutator) {
-				m.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(nil, nil)
+				m.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(optedOut, nil)
 			},
 			setupMetrics: func(m *MockMetricsRecorder) {
 				m.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
-				m.On("RecordMutation", "default", "skipped").Once()
+				m.On("RecordMutation", "default", testPodWorkload, "skipped", ReasonOptedOut).Once()
 			},
 			wantStatusCode: http.StatusOK,
 		},
@@ -184,7 +217,7 @@ func TestHandler_HandleMutateWithMetrics(t *testing.T) {
 			setupMutator: func(m *MockMutator) {},
 			setupMetrics: func(m *MockMetricsRecorder) {
 				m.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
-				m.On("RecordError", "decode").Once()
+				m.On("RecordError", CodeDecodeReview).Once()
 			},
 			wantStatusCode: http.StatusBadRequest,
 		},
@@ -194,7 +227,7 @@ func TestHandler_HandleMutateWithMetrics(t *testing.T) {
 			setupMutator: func(m *MockMutator) {},
 			setupMetrics: func(m *MockMetricsRecorder) {
 				m.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
-				m.On("RecordError", "read").Once()
+				m.On("RecordError", CodeRead).Once()
 			},
 			wantStatusCode: http.StatusBadRequest,
 		},
@@ -286,8 +319,473 @@ func createValidAdmissionReview(name, namespace string) admissionv1.AdmissionRev
 				Kind:    "Pod",
 			},
 			Object: runtime.RawExtension{
-				Raw: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"` + name + `","namespace":"` + namespace + `"}}`),
+				Raw: []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"` + name + `","namespace":"` + namespace + `"},"spec":{"containers":[{"name":"app","image":"nginx"}]}}`),
+			},
+		},
+	}
+}
+
+func TestHandler_HostnameWarnings(t *testing.T) {
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+	mockMetrics.On("RecordMutation", "default", testPodWorkload, "mutated", ReasonAlways).Once()
+	mockMetrics.On("RecordHostnameWarnings", "default", 1).Once()
+
+	mutator := NewMutator(&config.Config{NdotsValue: 2}, slog.Default())
+	h := NewHandlerWithMetrics(mutator, slog.Default(), mockMetrics)
+
+	review := createValidAdmissionReview("test-pod", "default")
+	review.Request.Object.Raw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test-pod"},` +
+		`"spec":{"containers":[{"name":"app","env":[{"name":"DB_HOST","value":"db.prod.svc"},{"name":"API","value":"https://api.github.com"}]}]}}`)
+
+	body, _ := json.Marshal(review)
+	req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.HandleMutate(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respReview admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+	require.Len(t, respReview.Response.Warnings, 1)
+	assert.Contains(t, respReview.Response.Warnings[0], `"db.prod.svc"`)
+	mockMetrics.AssertExpectations(t)
+}
+
+func TestHandler_HostnameWarnings_DecidedNdots(t *testing.T) {
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+	mockMetrics.On("RecordMutation", "default", testPodWorkload, "mutated", ReasonAlways).Once()
+	mockMetrics.On("RecordHostnameWarnings", "default", 1).Once()
+
+	// The expression decides ndots=1, under which the name is absolute first;
+	// with the configured ndots=2 it would not be.
+	mutator := NewMutator(&config.Config{NdotsValue: 2, NdotsExpression: "1", ExpressionCostLimit: 1000}, slog.Default())
+	h := NewHandlerWithMetrics(mutator, slog.Default(), mockMetrics)
+
+	review := createValidAdmissionReview("test-pod", "default")
+	review.Request.Object.Raw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test-pod"},` +
+		`"spec":{"containers":[{"name":"app","env":[{"name":"ORDERS","value":"orders.payments"}]}]}}`)
+
+	body, _ := json.Marshal(review)
+	w := httptest.NewRecorder()
+	h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+
+	var respReview admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+	require.Len(t, respReview.Response.Warnings, 1)
+	assert.Contains(t, respReview.Response.Warnings[0], "(ndots 5 -> 1)")
+	mockMetrics.AssertExpectations(t)
+}
+
+func TestHandler_DeprecatedKeyWarning(t *testing.T) {
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+	mockMetrics.On("RecordMutation", "default", testPodWorkload, "skipped", ReasonOptedOut).Once()
+	mockMetrics.On("RecordDeprecatedKey", "default", "change-ndots").Once()
+
+	mutator := NewMutator(&config.Config{
+		NdotsValue:           2,
+		AnnotationKey:        "ndots.example.com/change",
+		AnnotationKeyAliases: []string{"change-ndots"},
+		AnnotationMode:       "opt-out",
+	}, slog.Default())
+	h := NewHandlerWithMetrics(mutator, slog.Default(), mockMetrics)
+
+	review := createValidAdmissionReview("test-pod", "default")
+	review.Request.Object.Raw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test-pod","annotations":{"change-ndots":"false"}}}`)
+
+	body, _ := json.Marshal(review)
+	req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.HandleMutate(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respReview admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+	assert.Nil(t, respReview.Response.Patch)
+	require.Len(t, respReview.Response.Warnings, 1)
+	assert.Contains(t, respReview.Response.Warnings[0], `"change-ndots" is deprecated, use "ndots.example.com/change"`)
+	mockMetrics.AssertExpectations(t)
+}
+
+func TestHandler_DeprecatedKeyWarning_KeyNotDeciding(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		namespace string
+		reason    ReasonCode
+	}{
+		{name: "excluded namespace", mode: "opt-out", namespace: "kube-system", reason: ReasonNamespaceExcluded},
+		{name: "always mode", mode: "always", namespace: "default", reason: ReasonAlways},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMetrics := new(MockMetricsRecorder)
+			mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+			mockMetrics.On("RecordMutation", tt.namespace, testPodWorkload, mock.Anything, tt.reason).Once()
+
+			mutator := NewMutator(&config.Config{
+				NdotsValue:           2,
+				AnnotationKey:        "ndots.example.com/change",
+				AnnotationKeyAliases: []string{"change-ndots"},
+				AnnotationMode:       tt.mode,
+				NamespaceExclude:     []string{"kube-system"},
+			}, slog.Default())
+			h := NewHandlerWithMetrics(mutator, slog.Default(), mockMetrics)
+
+			review := createValidAdmissionReview("test-pod", tt.namespace)
+			review.Request.Object.Raw = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"test-pod","annotations":{"change-ndots":"false"}},` +
+				`"spec":{"containers":[{"name":"app"}]}}`)
+			body, _ := json.Marshal(review)
+			w := httptest.NewRecorder()
+			h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+
+			var respReview admissionv1.AdmissionReview
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+			assert.Empty(t, respReview.Response.Warnings)
+			mockMetrics.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_EnforcementSchedule(t *testing.T) {
+	auditUntil := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	warnUntil := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantAction   string
+		wantPatch    bool
+		wantWarnings []string
+	}{
+		{"audit phase", auditUntil.Add(-time.Hour), "audit", false, nil},
+		{"warn phase", auditUntil.Add(time.Hour), "warn", false,
+			[]string{"ndots will be enforced from 2026-12-01T00:00:00Z; this pod's DNS config will then be mutated"}},
+		{"enforce phase", warnUntil, "mutated", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMutator := new(MockMutator)
+			mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
+				mutateDecision(PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}}),
+				nil,
+			)
+			mockMetrics := new(MockMetricsRecorder)
+			mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+			mockMetrics.On("RecordMutation", "default", testPodWorkload, tt.wantAction, ReasonAlways).Once()
+
+			h := NewHandlerWithMetrics(mockMutator, slog.Default(), mockMetrics)
+			h.SetSchedule(NewSchedule(auditUntil, warnUntil, func() time.Time { return tt.now }))
+
+			body, _ := json.Marshal(createValidAdmissionReview("test-pod", "default"))
+			req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			h.HandleMutate(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var respReview admissionv1.AdmissionReview
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+			assert.True(t, respReview.Response.Allowed)
+			assert.Equal(t, tt.wantPatch, len(respReview.Response.Patch) > 0)
+			assert.Equal(t, tt.wantWarnings, respReview.Response.Warnings)
+			mockMetrics.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_DeferredEventsAndWarnings(t *testing.T) {
+	auditUntil := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	warnUntil := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	decision := mutateDecision(PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}})
+	decision.KeyIssues = KeyIssues{
+		DeprecatedAlias: "change-ndots", Key: "ndots.example.com/change",
+		InvalidKey: "change-ndots", InvalidValue: "yes",
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantEvents   int
+		wantWarnings int
+	}{
+		{"audit phase", auditUntil.Add(-time.Hour), 0, 0},
+		{"warn phase", auditUntil.Add(time.Hour), 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMutator := new(MockMutator)
+			mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(decision, nil)
+			recorder := &stubEventRecorder{}
+			h := NewHandler(mockMutator, slog.Default())
+			h.SetEvents(recorder)
+			h.SetSchedule(NewSchedule(auditUntil, warnUntil, func() time.Time { return tt.now }))
+
+			body, _ := json.Marshal(createValidAdmissionReview("test-pod", "default"))
+			w := httptest.NewRecorder()
+			h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+
+			require.Equal(t, http.StatusOK, w.Code)
+			var respReview admissionv1.AdmissionReview
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+			assert.True(t, respReview.Response.Allowed)
+			assert.Empty(t, respReview.Response.Patch)
+			assert.Len(t, recorder.events, tt.wantEvents)
+			assert.Len(t, respReview.Response.Warnings, tt.wantWarnings)
+		})
+	}
+}
+
+func TestHandler_ShadowDoesNotAffectResponse(t *testing.T) {
+	mockMutator := new(MockMutator)
+	mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(optedOut, nil)
+	candidate := new(MockMutator)
+	candidate.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
+		mutateDecision(PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}}),
+		nil,
+	)
+
+	mockMetrics := new(MockMetricsRecorder)
+	mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+	mockMetrics.On("RecordShadowResult", "default", ShadowWouldMutate).Once()
+	mockMetrics.On("RecordMutation", "default", testPodWorkload, "skipped", ReasonOptedOut).Once()
+
+	h := NewHandlerWithMetrics(mockMutator, slog.Default(), mockMetrics)
+	h.SetShadow(NewShadow(candidate, 1, slog.Default()))
+
+	body, _ := json.Marshal(createValidAdmissionReview("test-pod", "default"))
+	req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	h.HandleMutate(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var respReview admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&respReview))
+	assert.True(t, respReview.Response.Allowed)
+	assert.Empty(t, respReview.Response.Patch)
+	candidate.AssertExpectations(t)
+	mockMetrics.AssertExpectations(t)
+}
+
+// stubEventRecorder collects events as "type reason message" strings.
+type stubEventRecorder struct {
+	events []string
+}
+
+func (r *stubEventRecorder) PodEvent(_ *corev1.Pod, _ string, eventType, reason, message string) {
+	r.events = append(r.events, eventType+" "+reason+" "+message)
+}
+
+func TestHandler_Events(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		namespace  string
+		pod        string
+		dryRun     bool
+		wantEvents []string
+	}{
+		{
+			name:       "mutated",
+			mode:       "opt-out",
+			pod:        `{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}]}}`,
+			wantEvents: []string{"Normal NdotsMutated set DNS option ndots to 2"},
+		},
+		{
+			name:       "skipped",
+			mode:       "opt-out",
+			pod:        `{"metadata":{"name":"web","annotations":{"change-ndots":"false"}}}`,
+			wantEvents: nil,
+		},
+		{
+			name:       "dry run",
+			mode:       "opt-out",
+			pod:        `{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}]}}`,
+			dryRun:     true,
+			wantEvents: nil,
+		},
+		{
+			name: "invalid value",
+			mode: "opt-in",
+			pod:  `{"metadata":{"name":"web","annotations":{"change-ndots":"yes"}}}`,
+			wantEvents: []string{
+				`Warning InvalidNdotsKeyValue ndots key "change-ndots" has invalid value "yes", expected "true" or "false"`,
 			},
 		},
+		{
+			name:       "invalid value in excluded namespace",
+			mode:       "opt-in",
+			namespace:  "kube-system",
+			pod:        `{"metadata":{"name":"web","annotations":{"change-ndots":"yes"}}}`,
+			wantEvents: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutator := NewMutator(&config.Config{
+				NdotsValue:       2,
+				AnnotationKey:    "change-ndots",
+				AnnotationMode:   tt.mode,
+				NamespaceExclude: []string{"kube-system"},
+			}, slog.Default())
+			recorder := &stubEventRecorder{}
+			h := NewHandler(mutator, slog.Default())
+			h.SetEvents(recorder)
+
+			namespace := tt.namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+			review := createValidAdmissionReview("web", namespace)
+			review.Request.Object.Raw = []byte(tt.pod)
+			review.Request.DryRun = &tt.dryRun
+			body, _ := json.Marshal(review)
+			req := httptest.NewRequest("POST", "/mutate", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			h.HandleMutate(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantEvents, recorder.events)
+		})
+	}
+}
+
+func TestDescribePatch(t *testing.T) {
+	m := NewMutator(&config.Config{NdotsValue: 3}, slog.Default())
+	value := "5"
+	pods := map[string]*corev1.Pod{
+		"add dnsConfig": {},
+		"add options":   {Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{}}},
+		"append option": {Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+			Options: []corev1.PodDNSConfigOption{{Name: "timeout"}},
+		}}},
+		"replace value": {Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+			Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &value}},
+		}}},
+	}
+	for name, pod := range pods {
+		t.Run(name, func(t *testing.T) {
+			desired := pod.DeepCopy()
+			require.True(t, setNdots(desired, m.ndotsValue))
+			patch, err := CreatePatch(pod, desired)
+			require.NoError(t, err)
+			assert.Equal(t, "set DNS option ndots to 3", describePatch(patch))
+		})
+	}
+
+	t.Run("env rewrites", func(t *testing.T) {
+		patch := []PatchOperation{{Op: "replace", Path: "/spec/containers/0/env/0/value", Value: "db.shop.svc.cluster.local."}}
+		assert.Equal(t, "rewrote 1 env value(s) to FQDNs", describePatch(patch))
+	})
+}
+
+func TestHandler_RecordsDecisions(t *testing.T) {
+	mutator := NewMutator(&config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}, slog.Default())
+	ring := NewDecisionRing(10)
+	h := NewHandler(mutator, slog.Default())
+	h.SetDecisionRing(ring)
+
+	pods := []string{
+		`{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}]}}`,
+		`{"metadata":{"name":"web","annotations":{"change-ndots":"false"}}}`,
+		`{"spec":"not a pod spec"}`,
+	}
+	for _, pod := range pods {
+		review := createValidAdmissionReview("web", "default")
+		review.Request.Object.Raw = []byte(pod)
+		body, _ := json.Marshal(review)
+		w := httptest.NewRecorder()
+		h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
 	}
+
+	records := ring.List("", "", 0)
+	require.Len(t, records, 3)
+
+	assert.Equal(t, "error", records[0].Decision)
+
+	assert.Equal(t, "skipped", records[1].Decision)
+	assert.Equal(t, "opted-out", records[1].Reason)
+	assert.Equal(t, `change-ndots="false"`, records[1].Rule)
+	assert.Equal(t, 5, records[1].NdotsBefore)
+	assert.Equal(t, 5, records[1].NdotsAfter)
+
+	assert.Equal(t, "mutated", records[2].Decision)
+	assert.Equal(t, "not-opted-out", records[2].Reason)
+	assert.Equal(t, "change-ndots unset", records[2].Rule)
+	assert.Equal(t, "default", records[2].Namespace)
+	assert.Equal(t, "web", records[2].Name)
+	assert.Equal(t, "Pod/web", records[2].Workload)
+	assert.Equal(t, 5, records[2].NdotsBefore)
+	assert.Equal(t, 2, records[2].NdotsAfter)
+}
+
+func TestHandler_LeanResponse(t *testing.T) {
+	mockMutator := new(MockMutator)
+	mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(optedOut, nil)
+	h := NewHandler(mockMutator, slog.Default())
+
+	body, _ := json.Marshal(createValidAdmissionReview("web", "default"))
+	w := httptest.NewRecorder()
+	h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+
+	var review map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &review))
+	assert.Equal(t, `"admission.k8s.io/v1"`, string(review["apiVersion"]))
+	assert.Equal(t, `"AdmissionReview"`, string(review["kind"]))
+	assert.JSONEq(t, `{"uid":"test-uid","allowed":true}`, string(review["response"]))
+	assert.NotContains(t, review, "request")
+}
+
+func BenchmarkHandler_WriteResponse(b *testing.B) {
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(largePodReview("default", nil, 3, 300), &review); err != nil {
+		b.Fatal(err)
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	review.Response = &admissionv1.AdmissionResponse{
+		UID:       review.Request.UID,
+		Allowed:   true,
+		Patch:     []byte(`[{"op":"add","path":"/spec/dnsConfig","value":{"options":[{"name":"ndots","value":"2"}]}}]`),
+		PatchType: &patchType,
+	}
+	h := NewHandler(new(MockMutator), slog.New(slog.DiscardHandler))
+
+	b.Run("lean", func(b *testing.B) {
+		b.ReportAllocs()
+		var size int
+		for b.Loop() {
+			w := httptest.NewRecorder()
+			h.writeResponse(w, review.Response)
+			size = w.Body.Len()
+		}
+		b.ReportMetric(float64(size), "bytes/response")
+	})
+
+	// echoed encodes the whole review, request included, for comparison.
+	b.Run("echoed", func(b *testing.B) {
+		b.ReportAllocs()
+		var size int
+		for b.Loop() {
+			w := httptest.NewRecorder()
+			respBytes, err := json.Marshal(review)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _ = w.Write(respBytes)
+			size = w.Body.Len()
+		}
+		b.ReportMetric(float64(size), "bytes/response")
+	})
 }
@@ -0,0 +1,332 @@
+package admission
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/publicsuffix"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultClusterFirstNdots is the ndots value kubelet writes into resolv.conf
+// for ClusterFirst pods when the pod spec does not set one.
+const defaultClusterFirstNdots = 5
+
+// defaultClusterDomain is used when the configuration leaves the cluster
+// domain empty.
+const defaultClusterDomain = "cluster.local"
+
+// Resolution describes how the lookup of a hostname changes between two
+// ndots values.
+type Resolution string
+
+const (
+	// ResolutionUnchanged means the name is looked up in the same order.
+	ResolutionUnchanged Resolution = "unchanged"
+	// ResolutionFaster means the name now resolves with fewer queries.
+	ResolutionFaster Resolution = "faster"
+	// ResolutionSlower means the name now needs at least one extra query.
+	ResolutionSlower Resolution = "slower"
+)
+
+// Hostname sources within a container spec.
+const (
+	SourceEnv     = "env"
+	SourceArgs    = "args"
+	SourceCommand = "command"
+)
+
+// HostnameFinding is a hostname referenced by a container whose lookup order
+// changes when ndots moves from OldNdots to Ndots.
+type HostnameFinding struct {
+	Container  string
+	Source     string
+	Field      string
+	Hostname   string
+	Dots       int
+	OldNdots   int
+	Ndots      int
+	Resolution Resolution
+	// FQDN is the absolute name of an in-cluster Hostname, if it is
+	// recognisably one.
+	FQDN string
+}
+
+// Warning returns a short, human readable admission warning for the finding.
+// Slower lookups come with the fix: the fully-qualified name for in-cluster
+// names now tried as absolute names first, a trailing '.' for public names
+// now tried via the search list first.
+func (f HostnameFinding) Warning() string {
+	absoluteFirst := f.Dots >= f.Ndots
+	msg := fmt.Sprintf("container %q %s %s: %q has %d dot(s) and is now looked up %s, not %s (ndots %d -> %d)",
+		f.Container, f.Source, f.Field, f.Hostname, f.Dots,
+		lookupOrder(absoluteFirst), lookupOrder(!absoluteFirst), f.OldNdots, f.Ndots)
+	switch {
+	case f.Resolution != ResolutionSlower:
+		return msg
+	case !absoluteFirst:
+		return msg + fmt.Sprintf("; use %q to skip the search list", f.Hostname+".")
+	case f.FQDN != "":
+		return msg + fmt.Sprintf("; use the fully-qualified name %q", f.FQDN)
+	default:
+		return msg + "; use the fully-qualified name ending in '.'"
+	}
+}
+
+// lookupOrder describes where the resolver starts for a name tried as an
+// absolute name first or not.
+func lookupOrder(absoluteFirst bool) string {
+	if absoluteFirst {
+		return "as an absolute name first"
+	}
+	return "via the search list first"
+}
+
+// nonHostSuffixes are final labels that mark a dotted token as a file name or
+// similar rather than a hostname.
+var nonHostSuffixes = map[string]bool{
+	"conf": true, "cfg": true, "crt": true, "csv": true, "env": true,
+	"html": true, "ini": true, "jar": true, "js": true, "json": true,
+	"key": true, "log": true, "pem": true, "pid": true, "properties": true,
+	"py": true, "sh": true, "sock": true, "sql": true, "tar": true,
+	"tgz": true, "toml": true, "txt": true, "xml": true, "yaml": true,
+	"yml": true, "zip": true,
+}
+
+// Lint reports the hostnames referenced by the container env values, args
+// and commands of pod in namespace whose lookup order changes when ndots moves
+// from the pod's current value to newNdots. Env values that the FQDN rewrite
+// fixes are not reported.
+func (m *Mutator) Lint(namespace string, pod *corev1.Pod, newNdots int) []HostnameFinding {
+	oldNdots := currentNdots(pod)
+	if oldNdots == newNdots {
+		return nil
+	}
+
+	skip := ""
+	if m.rewriteEnabled(namespace) {
+		skip = namespace
+	}
+	var findings []HostnameFinding
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, c := range containers {
+		for _, env := range c.Env {
+			findings = m.appendFindings(findings, namespace, c.Name, SourceEnv, env.Name, env.Value, oldNdots, newNdots, skip)
+		}
+		for i, arg := range c.Args {
+			findings = m.appendFindings(findings, namespace, c.Name, SourceArgs, strconv.Itoa(i), arg, oldNdots, newNdots, "")
+		}
+		for i, cmd := range c.Command {
+			findings = m.appendFindings(findings, namespace, c.Name, SourceCommand, strconv.Itoa(i), cmd, oldNdots, newNdots, "")
+		}
+	}
+	return findings
+}
+
+// appendFindings classifies the hostnames in value, referenced by a pod in
+// namespace. If rewriteNamespace is set, names the FQDN rewrite qualifies for
+// that namespace are skipped.
+func (m *Mutator) appendFindings(findings []HostnameFinding, namespace, container, source, field, value string, oldNdots, newNdots int, rewriteNamespace string) []HostnameFinding {
+	for _, host := range ExtractHostnames(value) {
+		if rewriteNamespace != "" && qualifyServiceName(host, rewriteNamespace, m.clusterDomain) != "" {
+			continue
+		}
+		res := ClassifyHostname(host, namespace, m.clusterDomain, oldNdots, newNdots)
+		if res == ResolutionUnchanged {
+			continue
+		}
+		findings = append(findings, HostnameFinding{
+			Container:  container,
+			Source:     source,
+			Field:      field,
+			Hostname:   host,
+			Dots:       strings.Count(host, "."),
+			OldNdots:   oldNdots,
+			Ndots:      newNdots,
+			Resolution: res,
+			FQDN:       qualifyServiceName(host, namespace, m.clusterDomain),
+		})
+	}
+	return findings
+}
+
+// currentNdots returns the ndots value the pod resolves with today.
+func currentNdots(pod *corev1.Pod) int {
+	if pod.Spec.DNSConfig != nil {
+		if idx := findNdotsIndex(pod.Spec.DNSConfig.Options); idx != -1 {
+			if v := pod.Spec.DNSConfig.Options[idx].Value; v != nil {
+				if n, err := strconv.Atoi(*v); err == nil {
+					return n
+				}
+			}
+		}
+	}
+	return defaultClusterFirstNdots
+}
+
+// ClassifyHostname reports how the lookup of host, referenced by a pod in
+// namespace, changes when ndots moves from oldNdots to newNdots in a cluster
+// using clusterDomain. Names with at least ndots dots are tried as absolute
+// names before the search list; in-cluster names only resolve via the search
+// list, so trying them as absolute first costs an upstream query.
+func ClassifyHostname(host, namespace, clusterDomain string, oldNdots, newNdots int) Resolution {
+	if strings.HasSuffix(host, ".") {
+		return ResolutionUnchanged
+	}
+
+	dots := strings.Count(host, ".")
+	wasAbsoluteFirst := dots >= oldNdots
+	isAbsoluteFirst := dots >= newNdots
+	if wasAbsoluteFirst == isAbsoluteFirst {
+		return ResolutionUnchanged
+	}
+
+	// In-cluster names get slower when they move to absolute-first; public
+	// names get slower when they move back behind the search list.
+	if isAbsoluteFirst == needsSearch(host, namespace, clusterDomain) {
+		return ResolutionSlower
+	}
+	return ResolutionFaster
+}
+
+// needsSearch reports whether host, referenced by a pod in namespace, only
+// resolves after search list expansion, i.e. it is a partially qualified
+// in-cluster name such as "db.prod.svc".
+func needsSearch(host, namespace, clusterDomain string) bool {
+	if host == clusterDomain || strings.HasSuffix(host, "."+clusterDomain) {
+		return false
+	}
+
+	// service.namespace names in the pod's own namespace and .svc names are
+	// in-cluster even if their last label is also a public suffix, as for a
+	// namespace named "dev" or "shop".
+	labels := strings.Split(host, ".")
+	switch last := labels[len(labels)-1]; {
+	case last == "svc", last == "cluster":
+		return true
+	case len(labels) == 2 && last == namespace:
+		return true
+	}
+
+	// Names under an ICANN-managed suffix are public; anything else (such as
+	// "orders.payments") can only be a service.namespace style name.
+	_, icann := publicsuffix.PublicSuffix(host)
+	return !icann
+}
+
+// ExtractHostnames returns the hostname-like values found in s. It understands
+// URLs, host:port pairs, user@host forms and flag=value arguments.
+func ExtractHostnames(s string) []string {
+	var hosts []string
+	for _, span := range hostnameSpans(s) {
+		hosts = append(hosts, s[span[0]:span[1]])
+	}
+	return hosts
+}
+
+// hostnameSpans returns the [start, end) offsets of the hostnames found in s.
+func hostnameSpans(s string) [][2]int {
+	var spans [][2]int
+	tokStart := -1
+	for i, r := range s + " " {
+		if !isHostTokenSeparator(r) {
+			if tokStart < 0 {
+				tokStart = i
+			}
+			continue
+		}
+		if tokStart >= 0 {
+			if start, end, ok := hostSpan(s[tokStart:i]); ok {
+				spans = append(spans, [2]int{tokStart + start, tokStart + end})
+			}
+			tokStart = -1
+		}
+	}
+	return spans
+}
+
+func isHostTokenSeparator(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`,;="'()[]{}`, r)
+}
+
+// hostSpan locates the host part of a single token such as a URL, a
+// user@host:port pair or a bare name.
+func hostSpan(tok string) (int, int, bool) {
+	start, end := 0, len(tok)
+	scheme := strings.Index(tok, "://")
+	if scheme >= 0 {
+		start = scheme + 3
+	}
+	if i := strings.IndexAny(tok[start:], "/?#"); i >= 0 {
+		end = start + i
+	}
+	if i := strings.LastIndexByte(tok[start:end], '@'); i >= 0 {
+		start += i + 1
+	}
+	if i := strings.LastIndexByte(tok[start:end], ':'); i >= 0 {
+		if _, err := strconv.ParseUint(tok[start+i+1:end], 10, 16); err != nil {
+			return 0, 0, false
+		}
+		end = start + i
+	}
+
+	host := tok[start:end]
+	if !isHostname(host) {
+		return 0, 0, false
+	}
+
+	// Cross-check URLs with the standard parser so that nested schemes such
+	// as "jdbc:mysql://" are accepted but malformed URLs are not.
+	if scheme >= 0 {
+		u, err := url.Parse(tok[scheme+1:])
+		if err != nil || u.Hostname() != host {
+			return 0, 0, false
+		}
+	}
+	return start, end, true
+}
+
+// isHostname reports whether s looks like a multi-label DNS name. Only lower
+// case names are accepted so that identifiers such as Java class names are not
+// mistaken for hostnames.
+func isHostname(s string) bool {
+	name := strings.TrimSuffix(s, ".")
+	if len(name) == 0 || len(name) > 253 || !strings.Contains(name, ".") {
+		return false
+	}
+	if net.ParseIP(name) != nil {
+		return false
+	}
+
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !isDNSLabel(label) {
+			return false
+		}
+	}
+
+	last := labels[len(labels)-1]
+	if nonHostSuffixes[last] {
+		return false
+	}
+	return strings.IndexFunc(last, func(r rune) bool { return r >= 'a' && r <= 'z' }) >= 0
+}
+
+func isDNSLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
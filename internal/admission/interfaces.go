@@ -1,7 +1,11 @@
 package admission
 
 import (
+	"context"
+
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // PatchOperation represents a JSON patch operation.
@@ -11,14 +15,80 @@ type PatchOperation struct {
 	Value interface{} `json:"value,omitempty"`
 }
 
-// PodMutator defines the interface for pod mutations.
+// PodMutator defines the interface for pod mutations. The Decision says
+// whether and why the pod is mutated and carries the patch. Mutators must
+// read the namespace from req, and give up once ctx is done.
 type PodMutator interface {
-	Mutate(pod *corev1.Pod) ([]PatchOperation, error)
+	Mutate(ctx context.Context, req Request, pod *corev1.Pod) (Decision, error)
+}
+
+// PodPrefilter is optionally implemented by a PodMutator that can decide to
+// skip a pod from its metadata and spec.dnsConfig alone, so the handler only
+// decodes the rest of the pod when it may be mutated. pod has no other spec
+// fields set. ok is false if the full pod is needed; d is then ignored.
+type PodPrefilter interface {
+	Prefilter(ctx context.Context, req Request, pod *corev1.Pod) (d Decision, ok bool)
+}
+
+// DecisionKeyer is optionally implemented by a PodMutator whose Decisions
+// can be cached. DecisionKey returns a key that is equal for pods the mutator
+// makes the same Decision for in req.Namespace, or false if the Decision for
+// pod cannot be cached.
+type DecisionKeyer interface {
+	DecisionKey(req Request, pod *corev1.Pod) (key string, ok bool)
+}
+
+// PodLinter is optionally implemented by a PodMutator to report hostnames
+// whose resolution changes with the mutation.
+type PodLinter interface {
+	Lint(namespace string, pod *corev1.Pod, ndots int) []HostnameFinding
+}
+
+// PodEventRecorder records Kubernetes Events about admitted pods, attached to
+// an object that exists once the pod is admitted. Implementations must not
+// block the admission request.
+type PodEventRecorder interface {
+	PodEvent(pod *corev1.Pod, namespace, eventType, reason, message string)
+}
+
+// OwnerLookup resolves the controlling owners of an object, nearest first.
+type OwnerLookup interface {
+	ControllerChain(namespace string, refs []metav1.OwnerReference) []metav1.Object
+}
+
+// NamespaceLookup returns a namespace by name.
+type NamespaceLookup interface {
+	Namespace(name string) (*corev1.Namespace, bool)
+}
+
+// ExpressionRecorder records policy expression evaluations. name is one of
+// the Expression* names; result is "true" or "false" for the condition, "ok"
+// for the ndots expression, or "error".
+type ExpressionRecorder interface {
+	RecordExpression(name, result string, seconds float64)
+}
+
+// NamespacePolicy overrides parts of the mutation policy for one namespace.
+// Zero fields keep the cluster-wide setting.
+type NamespacePolicy struct {
+	AnnotationMode string
+	NdotsValue     *int
+	Exempt         labels.Selector
+}
+
+// PolicySource returns the policy override of a namespace, if any.
+type PolicySource interface {
+	NamespacePolicy(namespace string) (*NamespacePolicy, bool)
 }
 
 // MetricsRecorder defines the interface for recording metrics.
 type MetricsRecorder interface {
-	RecordMutation(namespace, action string)
-	RecordError(errorType string)
+	RecordMutation(namespace string, workload Workload, action string, reason ReasonCode)
+	RecordError(code ErrorCode)
+	RecordFailure(code ErrorCode, action string)
 	ObserveRequestDuration(seconds float64)
+	RecordHostnameWarnings(namespace string, count int)
+	RecordShadowResult(namespace, result string)
+	RecordDeprecatedKey(namespace, key string)
+	RecordDecisionCache(result string)
 }
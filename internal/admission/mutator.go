@@ -1,92 +1,289 @@
 package admission
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/expression"
 )
 
 type Mutator struct {
+	ndots             int
 	ndotsValue        string
 	annotationChecker *AnnotationChecker
 	namespaceFilter   *NamespaceFilter
+	clusterDomain     string
+	fqdnRewrite       map[string]bool
+	owners            OwnerLookup
+	policies          PolicySource
+	condition         *expression.Expression
+	ndotsExpr         *expression.Expression
+	namespaces        NamespaceLookup
+	exprMetrics       ExpressionRecorder
 	logger            *slog.Logger
 }
 
+// NewMutator creates a Mutator for cfg, which must be valid: it panics if
+// the policy expressions do not compile.
 func NewMutator(cfg *config.Config, logger *slog.Logger) *Mutator {
+	condition, err := cfg.CompileCondition()
+	if err != nil {
+		panic(fmt.Sprintf("invalid mutation condition: %v", err))
+	}
+	ndotsExpr, err := cfg.CompileNdotsExpression()
+	if err != nil {
+		panic(fmt.Sprintf("invalid ndots expression: %v", err))
+	}
+
+	clusterDomain := cfg.ClusterDomain
+	if clusterDomain == "" {
+		clusterDomain = defaultClusterDomain
+	}
+
+	fqdnRewrite := make(map[string]bool)
+	for _, ns := range cfg.FQDNRewriteNamespaces {
+		fqdnRewrite[ns] = true
+	}
+
 	return &Mutator{
+		ndots:             cfg.NdotsValue,
 		ndotsValue:        strconv.Itoa(cfg.NdotsValue),
-		annotationChecker: NewAnnotationChecker(cfg.AnnotationKey, cfg.AnnotationMode),
+		annotationChecker: NewAnnotationCheckerWithSource(cfg.AnnotationKey, cfg.AnnotationMode, cfg.AnnotationSource).WithAliases(cfg.AnnotationKeyAliases...),
 		namespaceFilter:   NewNamespaceFilter(cfg.NamespaceInclude, cfg.NamespaceExclude, logger),
+		clusterDomain:     clusterDomain,
+		fqdnRewrite:       fqdnRewrite,
+		condition:         condition,
+		ndotsExpr:         ndotsExpr,
 		logger:            logger,
 	}
 }
 
-func (m *Mutator) Mutate(pod *corev1.Pod) ([]PatchOperation, error) {
-	podName := getPodName(pod)
-	if !m.namespaceFilter.ShouldMutate(pod.Namespace) {
-		m.logger.Debug("skipping mutation due to namespace filter",
-			"namespace", pod.Namespace,
-			"name", podName,
-		)
-		return nil, nil
+// SetOwnerLookup makes pods without the opt-in/opt-out key inherit it from
+// their nearest controlling owner that has it.
+func (m *Mutator) SetOwnerLookup(owners OwnerLookup) {
+	m.owners = owners
+}
+
+// SetPolicySource applies per-namespace policy overrides from source.
+func (m *Mutator) SetPolicySource(source PolicySource) {
+	m.policies = source
+}
+
+// policy is the mutation policy in effect for one namespace.
+type policy struct {
+	// name is PolicyCluster or PolicyNamespace.
+	name       string
+	checker    *AnnotationChecker
+	ndots      int
+	ndotsValue string
+	// ndotsOverride is set if the namespace policy sets ndots, which then
+	// takes precedence over the ndots expression.
+	ndotsOverride bool
+	exempt        labels.Selector
+}
+
+// policyFor returns the cluster-wide policy with the namespace's override,
+// if any, applied.
+func (m *Mutator) policyFor(namespace string) policy {
+	p := policy{
+		name:       PolicyCluster,
+		checker:    m.annotationChecker,
+		ndots:      m.ndots,
+		ndotsValue: m.ndotsValue,
+	}
+	if m.policies == nil {
+		return p
+	}
+
+	override, ok := m.policies.NamespacePolicy(namespace)
+	if !ok {
+		return p
+	}
+	p.name = PolicyNamespace
+	if override.AnnotationMode != "" {
+		p.checker = m.annotationChecker.WithMode(override.AnnotationMode)
+	}
+	if override.NdotsValue != nil {
+		p.ndots = *override.NdotsValue
+		p.ndotsValue = strconv.Itoa(p.ndots)
+		p.ndotsOverride = true
+	}
+	p.exempt = override.Exempt
+	return p
+}
+
+// Mutate decides whether pod is mutated and returns the patch to apply. The
+// pod is evaluated in req.Namespace.
+func (m *Mutator) Mutate(ctx context.Context, req Request, pod *corev1.Pod) (Decision, error) {
+	if err := ctx.Err(); err != nil {
+		return Decision{}, err
+	}
+
+	d, err := m.decide(ctx, req, pod, m.exprMetrics, m.logger)
+	if err != nil {
+		return Decision{}, err
+	}
+	if !d.Mutates() {
+		m.logSkip(req, pod, d)
+	}
+	return d, nil
+}
+
+// logSkip logs that pod is not mutated, with the reason from d.
+func (m *Mutator) logSkip(req Request, pod *corev1.Pod, d Decision) {
+	m.logger.Debug("skipping mutation",
+		"namespace", req.Namespace,
+		"name", getPodName(pod),
+		"workload", WorkloadOf(pod).String(),
+		"reason", d.Reason,
+		"rule", d.Rule,
+	)
+}
+
+// decide is Mutate without the skip log. Expression evaluations are recorded
+// to recorder if it is not nil, and env rewrites are logged to logger.
+func (m *Mutator) decide(ctx context.Context, req Request, pod *corev1.Pod, recorder ExpressionRecorder, logger *slog.Logger) (Decision, error) {
+	namespace := req.Namespace
+	p, d, err := m.scope(ctx, req, pod, recorder)
+	if err != nil || !d.Mutates() {
+		return d, err
+	}
+
+	desired := pod.DeepCopy()
+	if setNdots(desired, p.ndotsValue) {
+		d.NdotsAfter = p.ndots
+	}
+	if m.rewriteEnabled(namespace) {
+		m.qualifyEnv(namespace, desired, logger)
+	}
+	if d.Patch, err = CreatePatch(pod, desired); err != nil {
+		return Decision{}, newError(CodePatchBuild, err)
+	}
+	if len(d.Patch) == 0 {
+		return skipDecision(ReasonAlreadyCompliant, d.Policy, d.Rule, d.NdotsBefore), nil
+	}
+	return d, nil
+}
+
+// scope returns the policy for pod in req.Namespace and a skip Decision if
+// the pod is not subject to it. Otherwise the Decision is to mutate, without
+// a patch. The policy's ndots value is computed by the ndots expression, if
+// any. Expression evaluations are recorded to recorder if it is not nil. The
+// Decision carries the issues with the opt-in/opt-out key if the key decided
+// it.
+func (m *Mutator) scope(ctx context.Context, req Request, pod *corev1.Pod, recorder ExpressionRecorder) (policy, Decision, error) {
+	namespace := req.Namespace
+	ndots := currentNdots(pod)
+	p, d, skip := m.filter(namespace, pod)
+	if skip {
+		return p, d, nil
 	}
 
-	if !m.annotationChecker.ShouldMutate(pod.Annotations) {
-		m.logger.Debug("skipping mutation due to annotation",
-			"namespace", pod.Namespace,
-			"name", podName,
-		)
-		return nil, nil
+	if m.condition != nil || (m.ndotsExpr != nil && !p.ndotsOverride) {
+		vars, err := m.expressionVars(req, pod)
+		if err != nil {
+			return p, Decision{}, err
+		}
+		ok, err := m.evalCondition(ctx, vars, recorder)
+		if err != nil {
+			return p, Decision{}, err
+		}
+		if !ok {
+			return p, skipDecision(ReasonConditionNotMet, p.name, "condition "+m.condition.Source(), ndots), nil
+		}
+		if m.ndotsExpr != nil && !p.ndotsOverride {
+			if p.ndots, err = m.evalNdots(ctx, vars, recorder); err != nil {
+				return p, Decision{}, err
+			}
+			p.ndotsValue = strconv.Itoa(p.ndots)
+		}
 	}
 
+	podLabels, annotations, owner := m.optInSource(namespace, pod, p.checker)
+	mutate, reason, rule := p.checker.Evaluate(podLabels, annotations)
+	if owner != "" {
+		rule += " on owner " + owner
+	}
+	if !mutate {
+		d = skipDecision(reason, p.name, rule, ndots)
+	} else {
+		d = Decision{
+			Outcome:     OutcomeMutate,
+			Reason:      reason,
+			Policy:      p.name,
+			Rule:        rule,
+			NdotsBefore: ndots,
+			NdotsAfter:  ndots,
+		}
+	}
+	d.KeyIssues = p.checker.Issues(podLabels, annotations)
+	return p, d, nil
+}
+
+// filter returns the policy for pod in namespace and a skip Decision if the
+// namespace filter or a policy exemption excludes the pod.
+func (m *Mutator) filter(namespace string, pod *corev1.Pod) (policy, Decision, bool) {
+	ndots := currentNdots(pod)
+	switch m.namespaceFilter.rejection(namespace) {
+	case "":
+	case "excluded":
+		return policy{}, skipDecision(ReasonNamespaceExcluded, "", "namespace exclude list", ndots), true
+	default:
+		return policy{}, skipDecision(ReasonNamespaceNotIncluded, "", "namespace include list", ndots), true
+	}
+
+	p := m.policyFor(namespace)
+	if p.exempt != nil && p.exempt.Matches(labels.Set(pod.Labels)) {
+		return p, skipDecision(ReasonPolicyExemption, p.name, "exempt "+p.exempt.String(), ndots), true
+	}
+	return p, Decision{}, false
+}
+
+// optInSource returns the labels and annotations the opt-in/opt-out key is
+// read from: the pod's own, or those of the nearest owner carrying the key.
+// It also returns the name of the owner the metadata belongs to, or "" for
+// the pod itself.
+func (m *Mutator) optInSource(namespace string, pod *corev1.Pod, checker *AnnotationChecker) (map[string]string, map[string]string, string) {
+	if m.owners == nil || checker.HasKey(pod.Labels, pod.Annotations) {
+		return pod.Labels, pod.Annotations, ""
+	}
+
+	for _, owner := range m.owners.ControllerChain(namespace, pod.OwnerReferences) {
+		if checker.HasKey(owner.GetLabels(), owner.GetAnnotations()) {
+			return owner.GetLabels(), owner.GetAnnotations(), owner.GetName()
+		}
+	}
+	return pod.Labels, pod.Annotations, ""
+}
+
+// setNdots sets the ndots option of pod to value and reports whether pod
+// changed.
+func setNdots(pod *corev1.Pod, value string) bool {
 	if pod.Spec.DNSConfig == nil {
-		return []PatchOperation{{
-			Op:   "add",
-			Path: "/spec/dnsConfig",
-			Value: map[string]interface{}{
-				"options": []map[string]interface{}{
-					{"name": "ndots", "value": m.ndotsValue},
-				},
-			},
-		}}, nil
-	}
-
-	if pod.Spec.DNSConfig.Options == nil {
-		return []PatchOperation{{
-			Op:   "add",
-			Path: "/spec/dnsConfig/options",
-			Value: []map[string]interface{}{
-				{"name": "ndots", "value": m.ndotsValue},
-			},
-		}}, nil
+		pod.Spec.DNSConfig = &corev1.PodDNSConfig{}
 	}
 
 	idx := findNdotsIndex(pod.Spec.DNSConfig.Options)
 	if idx == -1 {
-		return []PatchOperation{{
-			Op:   "add",
-			Path: "/spec/dnsConfig/options/-",
-			Value: map[string]interface{}{
-				"name": "ndots", "value": m.ndotsValue,
-			},
-		}}, nil
+		pod.Spec.DNSConfig.Options = append(pod.Spec.DNSConfig.Options, corev1.PodDNSConfigOption{
+			Name:  "ndots",
+			Value: &value,
+		})
+		return true
 	}
 
-	// Check if update needed
-	if pod.Spec.DNSConfig.Options[idx].Value != nil && *pod.Spec.DNSConfig.Options[idx].Value == m.ndotsValue {
-		return nil, nil
+	opt := &pod.Spec.DNSConfig.Options[idx]
+	if opt.Value != nil && *opt.Value == value {
+		return false
 	}
-
-	return []PatchOperation{{
-		Op:    "replace",
-		Path:  fmt.Sprintf("/spec/dnsConfig/options/%d/value", idx),
-		Value: m.ndotsValue,
-	}}, nil
+	opt.Value = &value
+	return true
 }
 
 func findNdotsIndex(options []corev1.PodDNSConfigOption) int {
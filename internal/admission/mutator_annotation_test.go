@@ -1,6 +1,7 @@
 package admission
 
 import (
+	"context"
 	"log/slog"
 	"testing"
 
@@ -71,8 +72,9 @@ func TestMutator_Mutate_Annotations(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mutator := NewMutator(tt.cfg, logger)
-			patches, err := mutator.Mutate(tt.pod)
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: tt.pod.Namespace}, tt.pod)
 			require.NoError(t, err)
+			patches := decision.Patch
 
 			if tt.wantPatch {
 				assert.NotEmpty(t, patches)
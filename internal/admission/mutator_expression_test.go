@@ -0,0 +1,114 @@
+package admission
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/expression"
+)
+
+// stubNamespaces returns fixed namespaces.
+type stubNamespaces map[string]*corev1.Namespace
+
+func (s stubNamespaces) Namespace(name string) (*corev1.Namespace, bool) {
+	ns, ok := s[name]
+	return ns, ok
+}
+
+// expressionCounts counts expression evaluations as "name result".
+type expressionCounts map[string]int
+
+func (c expressionCounts) RecordExpression(name, result string, _ float64) {
+	c[name+" "+result]++
+}
+
+func TestMutator_Mutate_Expressions(t *testing.T) {
+	newMutator := func(condition, ndots string) (*Mutator, expressionCounts) {
+		m := NewMutator(&config.Config{
+			NdotsValue:          2,
+			AnnotationKey:       "change-ndots",
+			AnnotationMode:      "opt-out",
+			MutationCondition:   condition,
+			NdotsExpression:     ndots,
+			ExpressionCostLimit: expression.DefaultCostLimit,
+		}, slog.Default())
+		m.SetNamespaceLookup(stubNamespaces{
+			"shop": {ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"dns": "fast"}}},
+		})
+		counts := expressionCounts{}
+		m.SetExpressionMetrics(counts)
+		return m, counts
+	}
+	pod := func(labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Labels: labels}}
+	}
+
+	t.Run("condition holds", func(t *testing.T) {
+		m, counts := newMutator(`object.metadata.labels.tier == "frontend"`, "")
+		d, err := m.Mutate(context.Background(), Request{Namespace: "default"}, pod(map[string]string{"tier": "frontend"}))
+		require.NoError(t, err)
+		assert.True(t, d.Mutates())
+		assert.Equal(t, expressionCounts{"condition true": 1}, counts)
+	})
+
+	t.Run("condition does not hold", func(t *testing.T) {
+		m, _ := newMutator(`object.metadata.labels.tier == "frontend"`, "")
+		d, err := m.Mutate(context.Background(), Request{Namespace: "default"}, pod(map[string]string{"tier": "backend"}))
+		require.NoError(t, err)
+		assert.Equal(t, ReasonConditionNotMet, d.Reason)
+		assert.Equal(t, `condition object.metadata.labels.tier == "frontend"`, d.Rule)
+	})
+
+	t.Run("condition on request", func(t *testing.T) {
+		m, _ := newMutator(`!request.dryRun && !("system:masters" in request.userInfo.groups)`, "")
+		req := Request{Namespace: "default", UserInfo: authenticationv1.UserInfo{Groups: []string{"system:masters"}}}
+		d, err := m.Mutate(context.Background(), req, pod(nil))
+		require.NoError(t, err)
+		assert.Equal(t, ReasonConditionNotMet, d.Reason)
+	})
+
+	t.Run("ndots from namespace", func(t *testing.T) {
+		m, counts := newMutator("", `has(namespaceObject.metadata.labels) && namespaceObject.metadata.labels.dns == "fast" ? 1 : 3`)
+		d, err := m.Mutate(context.Background(), Request{Namespace: "shop"}, pod(nil))
+		require.NoError(t, err)
+		assert.Equal(t, 1, d.NdotsAfter)
+
+		d, err = m.Mutate(context.Background(), Request{Namespace: "default"}, pod(nil))
+		require.Error(t, err, "namespaceObject is null for unknown namespaces")
+		assert.Contains(t, err.Error(), "evaluate ndots expression")
+		assert.Equal(t, expressionCounts{"ndots ok": 1, "ndots error": 1}, counts)
+		assert.Equal(t, Decision{}, d)
+	})
+
+	t.Run("ndots out of range", func(t *testing.T) {
+		m, _ := newMutator("", "16")
+		_, err := m.Mutate(context.Background(), Request{Namespace: "default"}, pod(nil))
+		assert.EqualError(t, err, "evaluate ndots expression: result 16 is not between 0 and 15")
+	})
+
+	t.Run("namespace policy takes precedence", func(t *testing.T) {
+		m, counts := newMutator("", "4")
+		three := 3
+		m.SetPolicySource(stubPolicySource{"shop": {NdotsValue: &three}})
+		d, err := m.Mutate(context.Background(), Request{Namespace: "shop"}, pod(nil))
+		require.NoError(t, err)
+		assert.Equal(t, 3, d.NdotsAfter)
+		assert.Empty(t, counts)
+	})
+
+	t.Run("compliance checks are not recorded", func(t *testing.T) {
+		m, counts := newMutator(`object.metadata.labels.tier == "frontend"`, "3")
+		p := pod(map[string]string{"tier": "frontend"})
+		p.Namespace = "default"
+		assert.Equal(t, 3, m.Check(p).Want)
+		assert.Empty(t, counts)
+	})
+}
@@ -1,6 +1,7 @@
 package admission
 
 import (
+	"context"
 	"log/slog"
 	"testing"
 
@@ -8,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
 )
@@ -62,8 +64,9 @@ func TestMutator_Mutate_Namespace(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mutator := NewMutator(tt.cfg, logger)
-			patches, err := mutator.Mutate(tt.pod)
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: tt.pod.Namespace}, tt.pod)
 			require.NoError(t, err)
+			patches := decision.Patch
 
 			if tt.wantPatch {
 				assert.NotEmpty(t, patches, "expected patch but got none")
@@ -73,3 +76,76 @@ func TestMutator_Mutate_Namespace(t *testing.T) {
 		})
 	}
 }
+
+func TestMutator_Mutate_RequestNamespace(t *testing.T) {
+	cfg := &config.Config{NdotsValue: 2, NamespaceExclude: []string{"kube-system"}}
+	mutator := NewMutator(cfg, slog.Default())
+
+	// Pods created by controllers carry no namespace at admission.
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "coredns-abc"}}
+
+	decision, err := mutator.Mutate(context.Background(), Request{Namespace: "kube-system"}, pod)
+	require.NoError(t, err)
+	assert.Equal(t, ReasonNamespaceExcluded, decision.Reason)
+	assert.Empty(t, decision.Patch)
+
+	decision, err = mutator.Mutate(context.Background(), Request{Namespace: "default"}, pod)
+	require.NoError(t, err)
+	assert.True(t, decision.Mutates())
+}
+
+// stubPolicySource returns fixed namespace policies.
+type stubPolicySource map[string]*NamespacePolicy
+
+func (s stubPolicySource) NamespacePolicy(namespace string) (*NamespacePolicy, bool) {
+	p, ok := s[namespace]
+	return p, ok
+}
+
+func TestMutator_Mutate_NamespacePolicy(t *testing.T) {
+	three := 3
+	exempt, err := labels.Parse("app=legacy")
+	require.NoError(t, err)
+
+	cfg := &config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}
+	mutator := NewMutator(cfg, slog.Default())
+	mutator.SetPolicySource(stubPolicySource{
+		"team-a": {NdotsValue: &three},
+		"team-b": {AnnotationMode: "opt-in"},
+		"team-c": {Exempt: exempt},
+	})
+
+	tests := []struct {
+		name      string
+		pod       *corev1.Pod
+		wantValue string
+	}{
+		{"no policy uses cluster value", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}, "2"},
+		{"policy overrides ndots", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}, "3"},
+		{"policy switches to opt-in", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"}}, ""},
+		{"opt-in policy honours annotation", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-b", Annotations: map[string]string{"change-ndots": "true"},
+		}}, "2"},
+		{"exempt pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-c", Labels: map[string]string{"app": "legacy"},
+		}}, ""},
+		{"non-exempt pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-c", Labels: map[string]string{"app": "web"},
+		}}, "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: tt.pod.Namespace}, tt.pod)
+			require.NoError(t, err)
+			patches := decision.Patch
+			if tt.wantValue == "" {
+				assert.Empty(t, patches)
+				return
+			}
+			require.Len(t, patches, 1)
+			opts := patches[0].Value.(map[string]interface{})["options"].([]interface{})
+			assert.Equal(t, tt.wantValue, opts[0].(map[string]interface{})["value"])
+		})
+	}
+}
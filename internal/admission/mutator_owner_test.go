@@ -0,0 +1,55 @@
+package admission
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+// stubOwnerLookup returns a fixed controller chain.
+type stubOwnerLookup struct {
+	chain []metav1.Object
+}
+
+func (s *stubOwnerLookup) ControllerChain(namespace string, refs []metav1.OwnerReference) []metav1.Object {
+	return s.chain
+}
+
+func TestMutator_Mutate_InheritFromOwner(t *testing.T) {
+	replicaSet := &metav1.ObjectMeta{Name: "web-7d9f8"}
+	deployment := &metav1.ObjectMeta{Name: "web", Annotations: map[string]string{"change-ndots": "true"}}
+	optedOut := &metav1.ObjectMeta{Name: "web", Annotations: map[string]string{"change-ndots": "false"}}
+
+	tests := []struct {
+		name        string
+		chain       []metav1.Object
+		annotations map[string]string
+		wantPatch   bool
+	}{
+		{"no owners", nil, nil, false},
+		{"inherits from deployment", []metav1.Object{replicaSet, deployment}, nil, true},
+		{"nearest owner wins", []metav1.Object{optedOut, deployment}, nil, false},
+		{"pod annotation wins", []metav1.Object{deployment}, map[string]string{"change-ndots": "false"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-in"}
+			mutator := NewMutator(cfg, slog.Default())
+			mutator.SetOwnerLookup(&stubOwnerLookup{chain: tt.chain})
+
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: pod.Namespace}, pod)
+			require.NoError(t, err)
+			patches := decision.Patch
+			assert.Equal(t, tt.wantPatch, len(patches) > 0)
+		})
+	}
+}
@@ -1,12 +1,14 @@
 package admission
 
 import (
+	"context"
 	"log/slog"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
 )
@@ -37,11 +39,10 @@ func TestMutator_Mutate(t *testing.T) {
 				// Assert specific structure of the value map
 				val, ok := res.(map[string]interface{})
 				require.True(t, ok)
-				opts, ok := val["options"].([]map[string]interface{})
+				opts, ok := val["options"].([]interface{})
 				require.True(t, ok)
 				require.Len(t, opts, 1)
-				assert.Equal(t, "ndots", opts[0]["name"])
-				assert.Equal(t, "2", opts[0]["value"])
+				assert.Equal(t, map[string]interface{}{"name": "ndots", "value": "2"}, opts[0])
 			},
 		},
 		{
@@ -55,11 +56,10 @@ func TestMutator_Mutate(t *testing.T) {
 			wantOp:       "add",
 			wantPath:     "/spec/dnsConfig/options",
 			wantValCheck: func(t *testing.T, res interface{}) {
-				val, ok := res.([]map[string]interface{})
+				val, ok := res.([]interface{})
 				require.True(t, ok)
 				require.Len(t, val, 1)
-				assert.Equal(t, "ndots", val[0]["name"])
-				assert.Equal(t, "2", val[0]["value"])
+				assert.Equal(t, map[string]interface{}{"name": "ndots", "value": "2"}, val[0])
 			},
 		},
 		{
@@ -120,8 +120,9 @@ func TestMutator_Mutate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			patches, err := mutator.Mutate(tt.pod)
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: tt.pod.Namespace}, tt.pod)
 			require.NoError(t, err)
+			patches := decision.Patch
 			require.Len(t, patches, tt.wantPatchLen)
 
 			if tt.wantPatchLen > 0 {
@@ -135,3 +136,81 @@ func TestMutator_Mutate(t *testing.T) {
 		})
 	}
 }
+
+func TestMutator_Mutate_Decision(t *testing.T) {
+	cfg := &config.Config{
+		NdotsValue:       2,
+		AnnotationKey:    "change-ndots",
+		AnnotationMode:   "opt-in",
+		NamespaceInclude: []string{"shop", "payments"},
+		NamespaceExclude: []string{"payments"},
+	}
+	mutator := NewMutator(cfg, slog.Default())
+	mutator.SetPolicySource(stubPolicySource{"shop": {AnnotationMode: "opt-out"}})
+	two, three := "2", "3"
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want Decision
+	}{
+		{
+			name: "excluded namespace",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "payments"}},
+			want: Decision{Outcome: OutcomeSkip, Reason: ReasonNamespaceExcluded, Rule: "namespace exclude list", NdotsBefore: 5, NdotsAfter: 5},
+		},
+		{
+			name: "namespace not included",
+			pod:  &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "web"}},
+			want: Decision{Outcome: OutcomeSkip, Reason: ReasonNamespaceNotIncluded, Rule: "namespace include list", NdotsBefore: 5, NdotsAfter: 5},
+		},
+		{
+			name: "opted out under namespace policy",
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "shop", Annotations: map[string]string{"change-ndots": "false"},
+			}},
+			want: Decision{Outcome: OutcomeSkip, Reason: ReasonOptedOut, Policy: PolicyNamespace, Rule: `change-ndots="false"`, NdotsBefore: 5, NdotsAfter: 5},
+		},
+		{
+			name: "already compliant",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop"},
+				Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+					Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &two}},
+				}},
+			},
+			want: Decision{Outcome: OutcomeSkip, Reason: ReasonAlreadyCompliant, Policy: PolicyNamespace, Rule: "change-ndots unset", NdotsBefore: 2, NdotsAfter: 2},
+		},
+		{
+			name: "mutated",
+			pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop"},
+				Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+					Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &three}},
+				}},
+			},
+			want: Decision{
+				Outcome: OutcomeMutate, Reason: ReasonNotOptedOut, Policy: PolicyNamespace, Rule: "change-ndots unset",
+				NdotsBefore: 3, NdotsAfter: 2,
+				Patch: []PatchOperation{{Op: "replace", Path: "/spec/dnsConfig/options/0/value", Value: "2"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := mutator.Mutate(context.Background(), Request{Namespace: tt.pod.Namespace}, tt.pod)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, decision)
+		})
+	}
+}
+
+func TestMutator_Mutate_Canceled(t *testing.T) {
+	mutator := NewMutator(&config.Config{NdotsValue: 2}, slog.Default())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := mutator.Mutate(ctx, Request{Namespace: "default"}, &corev1.Pod{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -28,21 +28,25 @@ func NewNamespaceFilter(include, exclude []string, logger *slog.Logger) *Namespa
 
 // ShouldMutate returns true if the namespace should be mutated.
 func (f *NamespaceFilter) ShouldMutate(namespace string) bool {
+	if reason := f.rejection(namespace); reason != "" {
+		f.logger.Debug("namespace "+reason, "namespace", namespace)
+		return false
+	}
+	return true
+}
+
+// rejection returns why namespace is not mutated, or "" if it is.
+func (f *NamespaceFilter) rejection(namespace string) string {
 	// Exclude takes priority
 	if f.exclude[namespace] {
-		f.logger.Debug("namespace excluded", "namespace", namespace)
-		return false
+		return "excluded"
 	}
 
 	// If include list is set, namespace must be in it
-	if len(f.include) > 0 {
-		allowed := f.include[namespace]
-		if !allowed {
-			f.logger.Debug("namespace not in include list", "namespace", namespace)
-		}
-		return allowed
+	if len(f.include) > 0 && !f.include[namespace] {
+		return "not in include list"
 	}
 
 	// Default: allow
-	return true
+	return ""
 }
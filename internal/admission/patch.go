@@ -0,0 +1,140 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// CreatePatch returns a JSON patch (RFC 6902) that turns original into
+// desired. Mutators edit a copy of the pod and leave building the patch to
+// CreatePatch, so paths never have to be written by hand.
+//
+// Objects are compared member by member and arrays element by element;
+// elements appended to an array are added with the "/-" path and trailing
+// elements removed from the end. Members are visited in sorted order, so the
+// patch is deterministic.
+func CreatePatch(original, desired *corev1.Pod) ([]PatchOperation, error) {
+	from, err := jsonValue(original)
+	if err != nil {
+		return nil, fmt.Errorf("marshal original pod: %w", err)
+	}
+	to, err := jsonValue(desired)
+	if err != nil {
+		return nil, fmt.Errorf("marshal desired pod: %w", err)
+	}
+	return diffValues("", from, to, nil), nil
+}
+
+// jsonValue returns v as decoded by encoding/json into an interface{}.
+func jsonValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func diffValues(path string, from, to interface{}, ops []PatchOperation) []PatchOperation {
+	switch f := from.(type) {
+	case map[string]interface{}:
+		if t, ok := to.(map[string]interface{}); ok {
+			return diffObjects(path, f, t, ops)
+		}
+	case []interface{}:
+		if t, ok := to.([]interface{}); ok {
+			return diffArrays(path, f, t, ops)
+		}
+	}
+
+	switch {
+	case reflect.DeepEqual(from, to):
+		return ops
+	case to == nil:
+		return append(ops, PatchOperation{Op: "remove", Path: path})
+	default:
+		return append(ops, PatchOperation{Op: "replace", Path: path, Value: to})
+	}
+}
+
+func diffObjects(path string, from, to map[string]interface{}, ops []PatchOperation) []PatchOperation {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointer(k)
+		f, inFrom := from[k]
+		t, inTo := to[k]
+		switch {
+		case !inTo:
+			ops = append(ops, PatchOperation{Op: "remove", Path: p})
+		case !inFrom:
+			ops = append(ops, PatchOperation{Op: "add", Path: p, Value: t})
+		default:
+			ops = diffValues(p, f, t, ops)
+		}
+	}
+	return ops
+}
+
+func diffArrays(path string, from, to []interface{}, ops []PatchOperation) []PatchOperation {
+	n := min(len(from), len(to))
+	for i := 0; i < n; i++ {
+		ops = diffValues(path+"/"+strconv.Itoa(i), from[i], to[i], ops)
+	}
+	for _, t := range to[n:] {
+		ops = append(ops, PatchOperation{Op: "add", Path: path + "/-", Value: t})
+	}
+	for i := len(from) - 1; i >= n; i-- {
+		ops = append(ops, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	return ops
+}
+
+// escapePointer escapes a member name for use in a JSON pointer (RFC 6901).
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// applyPatch returns a copy of pod with patch applied.
+func applyPatch(pod *corev1.Pod, patch []PatchOperation) (*corev1.Pod, error) {
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		return nil, fmt.Errorf("marshal pod: %w", err)
+	}
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("marshal patch: %w", err)
+	}
+	decoded, err := jsonpatch.DecodePatch(patchJSON)
+	if err != nil {
+		return nil, fmt.Errorf("decode patch: %w", err)
+	}
+	patched, err := decoded.Apply(podJSON)
+	if err != nil {
+		return nil, fmt.Errorf("apply patch: %w", err)
+	}
+	var out corev1.Pod
+	if err := json.Unmarshal(patched, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal patched pod: %w", err)
+	}
+	return &out, nil
+}
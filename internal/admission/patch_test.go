@@ -0,0 +1,262 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+// legacyPatch decodes a patch as the Mutator built it by hand before patches
+// were generated by CreatePatch.
+func legacyPatch(t *testing.T, s string) []PatchOperation {
+	t.Helper()
+	var patch []PatchOperation
+	require.NoError(t, json.Unmarshal([]byte(s), &patch))
+	return patch
+}
+
+// TestMutator_Mutate_LegacyPatches checks that the generated patches have the
+// same effect as the hand-built ones for every case the Mutator handled, and
+// are identical to them where the order of operations allows.
+func TestMutator_Mutate_LegacyPatches(t *testing.T) {
+	mutator := NewMutator(&config.Config{
+		NdotsValue:            2,
+		ClusterDomain:         "cluster.local",
+		FQDNRewriteNamespaces: []string{"shop"},
+	}, slog.Default())
+	five, two := "5", "2"
+
+	tests := []struct {
+		name      string
+		namespace string
+		spec      corev1.PodSpec
+		golden    string
+		sameOps   bool
+	}{
+		{
+			name:    "no dnsConfig",
+			golden:  `[{"op":"add","path":"/spec/dnsConfig","value":{"options":[{"name":"ndots","value":"2"}]}}]`,
+			sameOps: true,
+		},
+		{
+			name:    "dnsConfig without options",
+			spec:    corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Nameservers: []string{"10.0.0.10"}}},
+			golden:  `[{"op":"add","path":"/spec/dnsConfig/options","value":[{"name":"ndots","value":"2"}]}]`,
+			sameOps: true,
+		},
+		{
+			name: "options without ndots",
+			spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Options: []corev1.PodDNSConfigOption{{Name: "timeout", Value: &five}},
+			}},
+			golden:  `[{"op":"add","path":"/spec/dnsConfig/options/-","value":{"name":"ndots","value":"2"}}]`,
+			sameOps: true,
+		},
+		{
+			name: "ndots with another value",
+			spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Options: []corev1.PodDNSConfigOption{{Name: "edns0"}, {Name: "ndots", Value: &five}},
+			}},
+			golden:  `[{"op":"replace","path":"/spec/dnsConfig/options/1/value","value":"2"}]`,
+			sameOps: true,
+		},
+		{
+			name: "already compliant",
+			spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+				Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &two}},
+			}},
+			golden:  `[]`,
+			sameOps: true,
+		},
+		{
+			name:      "env rewrites",
+			namespace: "shop",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "migrate", Env: []corev1.EnvVar{
+					{Name: "DSN", Value: "postgres://db.prod.svc:5432/orders"},
+				}}},
+				Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{
+					{Name: "LOG_LEVEL", Value: "info"},
+					{Name: "ORDERS_URL", Value: "http://orders.shop:8080"},
+				}}},
+			},
+			golden: `[
+				{"op":"add","path":"/spec/dnsConfig","value":{"options":[{"name":"ndots","value":"2"}]}},
+				{"op":"replace","path":"/spec/initContainers/0/env/0/value","value":"postgres://db.prod.svc.cluster.local.:5432/orders"},
+				{"op":"replace","path":"/spec/containers/0/env/1/value","value":"http://orders.shop.svc.cluster.local.:8080"}
+			]`,
+		},
+		{
+			name:      "env rewrites on compliant pod",
+			namespace: "shop",
+			spec: corev1.PodSpec{
+				DNSConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &two}}},
+				Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{
+					{Name: "ORDERS_URL", Value: "http://orders.shop:8080"},
+					{Name: "CACHE", Value: "redis.cache.svc:6379"},
+				}}},
+			},
+			golden: `[
+				{"op":"replace","path":"/spec/containers/0/env/0/value","value":"http://orders.shop.svc.cluster.local.:8080"},
+				{"op":"replace","path":"/spec/containers/0/env/1/value","value":"redis.cache.svc.cluster.local.:6379"}
+			]`,
+			sameOps: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: tt.namespace},
+				Spec:       tt.spec,
+			}
+			golden := legacyPatch(t, tt.golden)
+
+			d, err := mutator.Mutate(context.Background(), Request{Namespace: tt.namespace}, pod)
+			require.NoError(t, err)
+
+			if tt.sameOps && len(golden) == 0 {
+				assert.Empty(t, d.Patch)
+			} else if tt.sameOps {
+				got, err := json.Marshal(d.Patch)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.golden, string(got))
+			}
+
+			want, err := applyPatch(pod, golden)
+			require.NoError(t, err)
+			got, err := applyPatch(pod, d.Patch)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+// TestMutator_Mutate_LegacyPatchGaps covers a pod the hand-built patch did
+// not apply to.
+func TestMutator_Mutate_LegacyPatchGaps(t *testing.T) {
+	mutator := NewMutator(&config.Config{NdotsValue: 2}, slog.Default())
+
+	tests := []struct {
+		name   string
+		spec   corev1.PodSpec
+		golden string
+		want   string
+	}{
+		{
+			name:   "empty options",
+			spec:   corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{}}},
+			golden: `[{"op":"add","path":"/spec/dnsConfig/options/-","value":{"name":"ndots","value":"2"}}]`,
+			want:   `[{"op":"add","path":"/spec/dnsConfig/options","value":[{"name":"ndots","value":"2"}]}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Spec: tt.spec}
+
+			_, err := applyPatch(pod, legacyPatch(t, tt.golden))
+			require.Error(t, err, "the hand-built patch does not apply")
+
+			d, err := mutator.Mutate(context.Background(), Request{}, pod)
+			require.NoError(t, err)
+			got, err := json.Marshal(d.Patch)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+
+			patched, err := applyPatch(pod, d.Patch)
+			require.NoError(t, err)
+			assert.Equal(t, 2, currentNdots(patched))
+		})
+	}
+}
+
+func TestCreatePatch(t *testing.T) {
+	base := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "web",
+				Labels:      map[string]string{"app": "web", "tier": "frontend"},
+				Annotations: map[string]string{"example.com/a~b": "1"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Args: []string{"a", "b", "c"}}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func(*corev1.Pod)
+		want string
+	}{
+		{
+			name: "unchanged",
+			edit: func(*corev1.Pod) {},
+			want: `null`,
+		},
+		{
+			name: "escaped member names",
+			edit: func(p *corev1.Pod) { p.Annotations["example.com/a~b"] = "2" },
+			want: `[{"op":"replace","path":"/metadata/annotations/example.com~1a~0b","value":"2"}]`,
+		},
+		{
+			name: "members added and removed",
+			edit: func(p *corev1.Pod) {
+				delete(p.Labels, "tier")
+				p.Labels["version"] = "v2"
+			},
+			want: `[
+				{"op":"remove","path":"/metadata/labels/tier"},
+				{"op":"add","path":"/metadata/labels/version","value":"v2"}
+			]`,
+		},
+		{
+			name: "array grown",
+			edit: func(p *corev1.Pod) { p.Spec.Containers[0].Args = append(p.Spec.Containers[0].Args, "d", "e") },
+			want: `[
+				{"op":"add","path":"/spec/containers/0/args/-","value":"d"},
+				{"op":"add","path":"/spec/containers/0/args/-","value":"e"}
+			]`,
+		},
+		{
+			name: "array shrunk",
+			edit: func(p *corev1.Pod) { p.Spec.Containers[0].Args = p.Spec.Containers[0].Args[:1] },
+			want: `[
+				{"op":"remove","path":"/spec/containers/0/args/2"},
+				{"op":"remove","path":"/spec/containers/0/args/1"}
+			]`,
+		},
+		{
+			name: "field cleared",
+			edit: func(p *corev1.Pod) { p.Annotations = nil },
+			want: `[{"op":"remove","path":"/metadata/annotations"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := base()
+			desired := original.DeepCopy()
+			tt.edit(desired)
+
+			patch, err := CreatePatch(original, desired)
+			require.NoError(t, err)
+			got, err := json.Marshal(patch)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+
+			patched, err := applyPatch(original, patch)
+			require.NoError(t, err)
+			assert.Equal(t, desired, patched)
+		})
+	}
+}
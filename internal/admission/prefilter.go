@@ -0,0 +1,116 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podHead is the part of a pod a PodPrefilter decides on. Decoding into it
+// skips the containers, volumes and other spec fields that make up most of a
+// large pod without allocating for them.
+type podHead struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              struct {
+		DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+	} `json:"spec,omitempty"`
+}
+
+// decodePodHead decodes the metadata and spec.dnsConfig of the pod in raw.
+func decodePodHead(raw []byte) (*corev1.Pod, error) {
+	var head podHead
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return nil, err
+	}
+	return &corev1.Pod{
+		ObjectMeta: head.ObjectMeta,
+		Spec:       corev1.PodSpec{DNSConfig: head.Spec.DNSConfig},
+	}, nil
+}
+
+// Prefilter decides whether pod is skipped from its metadata and DNS config.
+// It only does so when the decision cannot depend on the rest of the pod:
+// with policy expressions only the namespace filter and policy exemptions are
+// applied, and with FQDN rewriting enabled a pod is never already compliant.
+// It implements PodPrefilter.
+func (m *Mutator) Prefilter(ctx context.Context, req Request, pod *corev1.Pod) (Decision, bool) {
+	var d Decision
+	if m.condition != nil || m.ndotsExpr != nil {
+		var skip bool
+		if _, d, skip = m.filter(req.Namespace, pod); !skip {
+			return Decision{}, false
+		}
+	} else {
+		var err error
+		d, err = m.decide(ctx, req, pod, m.exprMetrics, m.logger)
+		if err != nil || d.Mutates() {
+			return Decision{}, false
+		}
+		if d.Reason == ReasonAlreadyCompliant && m.rewriteEnabled(req.Namespace) {
+			return Decision{}, false
+		}
+	}
+
+	m.logSkip(req, pod, d)
+	return d, true
+}
+
+// Prefilter skips pod if every link implements PodPrefilter and skips it. The
+// Decision is merged as in Mutate.
+func (c *Chain) Prefilter(ctx context.Context, req Request, pod *corev1.Pod) (Decision, bool) {
+	var (
+		primary Decision
+		issues  KeyIssues
+		steps   = make([]Step, 0, len(c.links))
+	)
+	for i, link := range c.links {
+		prefilter, ok := link.Mutator.(PodPrefilter)
+		if !ok {
+			return Decision{}, false
+		}
+		d, ok := prefilter.Prefilter(ctx, req, pod)
+		if !ok {
+			return Decision{}, false
+		}
+		steps = append(steps, Step{Name: link.Name, Outcome: d.Outcome, Reason: d.Reason, Rule: d.Rule})
+		if i == 0 {
+			primary = d
+		}
+		if issues == (KeyIssues{}) {
+			issues = d.KeyIssues
+		}
+	}
+
+	primary.Outcome = OutcomeSkip
+	primary.Steps = steps
+	primary.KeyIssues = issues
+	return primary, true
+}
+
+// prefilter answers the request for a pod the mutator skips based on the
+// pod's metadata and DNS config, decoded without the rest of the pod. It
+// returns nil if the pod needs a full decode: the mutator does not implement
+// PodPrefilter or needs the full pod, or a shadow policy evaluates every pod.
+// Pods that fail to decode are left to the full decode to report.
+func (h *Handler) prefilter(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	prefilter, ok := h.mutator.(PodPrefilter)
+	if !ok || h.shadow != nil {
+		return nil
+	}
+	pod, err := decodePodHead(req.Object.Raw)
+	if err != nil {
+		return nil
+	}
+
+	r := NewRequest(req, pod)
+	decision, ok := prefilter.Prefilter(ctx, r, pod)
+	if !ok {
+		return nil
+	}
+	keyWarnings := h.deprecatedKeyWarnings(r.Namespace, pod, decision)
+	h.invalidKeyEvent(r, pod, decision)
+	return h.skip(req.UID, r.Namespace, pod, decision, keyWarnings)
+}
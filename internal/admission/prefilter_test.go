@@ -0,0 +1,261 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/expression"
+)
+
+func TestDecodePodHead(t *testing.T) {
+	pod, err := decodePodHead([]byte(`{
+		"metadata":{"name":"web","labels":{"app":"web"},"annotations":{"change-ndots":"false"},
+			"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"web-1","uid":"u1","controller":true}]},
+		"spec":{"containers":[{"name":"app","env":[{"name":"DB","value":"db.shop"}]}],
+			"dnsConfig":{"options":[{"name":"ndots","value":"5"}]}}}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "web", pod.Name)
+	assert.Equal(t, map[string]string{"app": "web"}, pod.Labels)
+	assert.Equal(t, map[string]string{"change-ndots": "false"}, pod.Annotations)
+	assert.Equal(t, "ReplicaSet/web-1", WorkloadOf(pod).String())
+	assert.Equal(t, 5, currentNdots(pod))
+	assert.Empty(t, pod.Spec.Containers)
+
+	_, err = decodePodHead([]byte(`{"metadata":"web"}`))
+	assert.Error(t, err)
+}
+
+func TestMutator_Prefilter(t *testing.T) {
+	ndotsTwo := "2"
+	compliant := corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{
+		Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndotsTwo}},
+	}}
+	optedOut := metav1.ObjectMeta{Name: "web", Annotations: map[string]string{"change-ndots": "false"}}
+
+	tests := []struct {
+		name       string
+		cfg        config.Config
+		namespace  string
+		pod        *corev1.Pod
+		wantOK     bool
+		wantReason ReasonCode
+	}{
+		{
+			name:       "excluded namespace",
+			cfg:        config.Config{NamespaceExclude: []string{"kube-system"}},
+			namespace:  "kube-system",
+			pod:        &corev1.Pod{},
+			wantOK:     true,
+			wantReason: ReasonNamespaceExcluded,
+		},
+		{
+			name:       "opted out",
+			namespace:  "default",
+			pod:        &corev1.Pod{ObjectMeta: optedOut},
+			wantOK:     true,
+			wantReason: ReasonOptedOut,
+		},
+		{
+			name:       "already compliant",
+			namespace:  "default",
+			pod:        &corev1.Pod{Spec: compliant},
+			wantOK:     true,
+			wantReason: ReasonAlreadyCompliant,
+		},
+		{
+			name:      "already compliant with FQDN rewriting",
+			cfg:       config.Config{FQDNRewriteNamespaces: []string{"default"}},
+			namespace: "default",
+			pod:       &corev1.Pod{Spec: compliant},
+		},
+		{
+			name:      "mutated",
+			namespace: "default",
+			pod:       &corev1.Pod{},
+		},
+		{
+			name:       "excluded namespace with condition",
+			cfg:        config.Config{NamespaceExclude: []string{"kube-system"}, MutationCondition: "true"},
+			namespace:  "kube-system",
+			pod:        &corev1.Pod{},
+			wantOK:     true,
+			wantReason: ReasonNamespaceExcluded,
+		},
+		{
+			name:      "opted out with condition",
+			cfg:       config.Config{MutationCondition: "true"},
+			namespace: "default",
+			pod:       &corev1.Pod{ObjectMeta: optedOut},
+		},
+		{
+			name:      "already compliant with ndots expression",
+			cfg:       config.Config{NdotsExpression: "2"},
+			namespace: "default",
+			pod:       &corev1.Pod{Spec: compliant},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.NdotsValue = 2
+			cfg.AnnotationKey = "change-ndots"
+			cfg.AnnotationMode = "opt-out"
+			cfg.ExpressionCostLimit = expression.DefaultCostLimit
+			m := NewMutator(&cfg, slog.Default())
+
+			d, ok := m.Prefilter(context.Background(), Request{Namespace: tt.namespace}, tt.pod)
+			require.Equal(t, tt.wantOK, ok)
+			if ok {
+				assert.Equal(t, OutcomeSkip, d.Outcome)
+				assert.Equal(t, tt.wantReason, d.Reason)
+			}
+		})
+	}
+}
+
+func TestChain_Prefilter(t *testing.T) {
+	m := NewMutator(&config.Config{NdotsValue: 2, NamespaceExclude: []string{"kube-system"}}, slog.Default())
+	req := Request{Namespace: "kube-system"}
+
+	d, ok := NewChain(slog.Default(), ChainLink{Name: "ndots", Mutator: m}).Prefilter(context.Background(), req, &corev1.Pod{})
+	require.True(t, ok)
+	assert.Equal(t, ReasonNamespaceExcluded, d.Reason)
+	assert.Equal(t, []Step{{Name: "ndots", Outcome: OutcomeSkip, Reason: ReasonNamespaceExcluded, Rule: "namespace exclude list"}}, d.Steps)
+
+	_, ok = NewChain(slog.Default(),
+		ChainLink{Name: "ndots", Mutator: m},
+		ChainLink{Name: "search", Mutator: searchDomain("corp.example")},
+	).Prefilter(context.Background(), req, &corev1.Pod{})
+	assert.False(t, ok, "link without Prefilter needs the full pod")
+}
+
+func TestHandler_Prefilter(t *testing.T) {
+	m := NewMutator(&config.Config{NdotsValue: 2, NamespaceExclude: []string{"kube-system"}}, slog.Default())
+	// A spec the full decode rejects shows whether the pod was fully decoded.
+	raw := `{"metadata":{"name":"web"},"spec":{"containers":"not a list"}}`
+
+	tests := []struct {
+		name      string
+		namespace string
+		shadow    bool
+		wantError bool
+	}{
+		{name: "skipped without full decode", namespace: "kube-system"},
+		{name: "decoded when it may be mutated", namespace: "default", wantError: true},
+		{name: "decoded for shadow policy", namespace: "kube-system", shadow: true, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMetrics := new(MockMetricsRecorder)
+			mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+			if tt.wantError {
+				mockMetrics.On("RecordError", CodeDecodeObject).Once()
+				mockMetrics.On("RecordFailure", CodeDecodeObject, "allowed").Once()
+			} else {
+				mockMetrics.On("RecordMutation", tt.namespace, Workload{Kind: "Pod", Name: "web"}, "skipped", ReasonNamespaceExcluded).Once()
+			}
+			h := NewHandlerWithMetrics(NewChain(slog.Default(), ChainLink{Name: "ndots", Mutator: m}), slog.Default(), mockMetrics)
+			if tt.shadow {
+				h.SetShadow(NewShadow(m, 0, slog.Default()))
+			}
+
+			review := createValidAdmissionReview("web", tt.namespace)
+			review.Request.Object.Raw = []byte(raw)
+			body, _ := json.Marshal(review)
+			w := httptest.NewRecorder()
+			h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+
+			var resp admissionv1.AdmissionReview
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.True(t, resp.Response.Allowed)
+			assert.Empty(t, resp.Response.Patch)
+			mockMetrics.AssertExpectations(t)
+		})
+	}
+}
+
+// fullDecode is a Mutator that always needs the full pod.
+type fullDecode struct {
+	*Mutator
+}
+
+func (fullDecode) Prefilter(context.Context, Request, *corev1.Pod) (Decision, bool) {
+	return Decision{}, false
+}
+
+// largePodReview returns an AdmissionReview body for a pod with containers
+// containers of envVars environment variables each.
+func largePodReview(namespace string, annotations map[string]string, containers, envVars int) []byte {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations}}
+	for c := range containers {
+		container := corev1.Container{Name: fmt.Sprintf("app-%d", c), Image: "registry.example/app:1.0"}
+		for e := range envVars {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  fmt.Sprintf("SETTING_%d", e),
+				Value: "http://backend-" + strings.Repeat("x", e%16) + ".shop:8080",
+			})
+		}
+		pod.Spec.Containers = append(pod.Spec.Containers, container)
+	}
+
+	review := createValidAdmissionReview("web", namespace)
+	review.Request.Object.Raw, _ = json.Marshal(pod)
+	body, _ := json.Marshal(review)
+	return body
+}
+
+func BenchmarkHandler_HandleMutate(b *testing.B) {
+	logger := slog.New(slog.DiscardHandler)
+	m := NewMutator(&config.Config{
+		NdotsValue:       2,
+		AnnotationKey:    "change-ndots",
+		AnnotationMode:   "opt-out",
+		NamespaceExclude: []string{"kube-system"},
+	}, logger)
+
+	pods := []struct {
+		name string
+		body []byte
+	}{
+		{"excluded", largePodReview("kube-system", nil, 3, 300)},
+		{"opted-out", largePodReview("default", map[string]string{"change-ndots": "false"}, 3, 300)},
+		{"mutated", largePodReview("default", nil, 3, 300)},
+	}
+	mutators := []struct {
+		name    string
+		mutator PodMutator
+	}{
+		{"prefilter", m},
+		{"full-decode", fullDecode{m}},
+	}
+
+	for _, pod := range pods {
+		for _, mutator := range mutators {
+			b.Run(pod.name+"/"+mutator.name, func(b *testing.B) {
+				h := NewHandler(mutator.mutator, logger)
+				b.ReportAllocs()
+				for b.Loop() {
+					w := httptest.NewRecorder()
+					h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(pod.body)))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package admission
+
+import (
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Request is the admission request a pod is evaluated for.
+type Request struct {
+	UID       types.UID
+	Operation admissionv1.Operation
+	// Namespace is the namespace the pod is admitted to. Mutators must use it
+	// instead of pod.Namespace, which controllers usually leave empty at
+	// creation.
+	Namespace string
+	UserInfo  authenticationv1.UserInfo
+	// DryRun requests are not persisted; they must not have side effects.
+	DryRun bool
+}
+
+// NewRequest returns the Request for pod in the admission request req.
+func NewRequest(req *admissionv1.AdmissionRequest, pod *corev1.Pod) Request {
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = pod.Namespace
+	}
+	return Request{
+		UID:       req.UID,
+		Operation: req.Operation,
+		Namespace: namespace,
+		UserInfo:  req.UserInfo,
+		DryRun:    req.DryRun != nil && *req.DryRun,
+	}
+}
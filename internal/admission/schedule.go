@@ -0,0 +1,53 @@
+package admission
+
+import "time"
+
+// Phase is the enforcement phase of the webhook at a point in time.
+type Phase string
+
+const (
+	// PhaseAudit only logs and counts the mutations that would be applied.
+	PhaseAudit Phase = "audit"
+	// PhaseWarn additionally returns admission warnings announcing enforcement.
+	PhaseWarn Phase = "warn"
+	// PhaseEnforce applies mutations.
+	PhaseEnforce Phase = "enforce"
+)
+
+// Schedule phases enforcement in over time: audit-only until AuditUntil,
+// warnings-only until WarnUntil, enforce afterwards. Zero times skip a phase.
+type Schedule struct {
+	auditUntil time.Time
+	warnUntil  time.Time
+	now        func() time.Time
+}
+
+// NewSchedule creates a Schedule evaluated against the given clock.
+func NewSchedule(auditUntil, warnUntil time.Time, now func() time.Time) *Schedule {
+	return &Schedule{
+		auditUntil: auditUntil,
+		warnUntil:  warnUntil,
+		now:        now,
+	}
+}
+
+// Current returns the phase in effect now.
+func (s *Schedule) Current() Phase {
+	now := s.now()
+	switch {
+	case now.Before(s.auditUntil):
+		return PhaseAudit
+	case now.Before(s.warnUntil):
+		return PhaseWarn
+	default:
+		return PhaseEnforce
+	}
+}
+
+// EnforceFrom returns the time from which mutations are applied.
+func (s *Schedule) EnforceFrom() time.Time {
+	if s.warnUntil.After(s.auditUntil) {
+		return s.warnUntil
+	}
+	return s.auditUntil
+}
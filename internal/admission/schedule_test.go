@@ -0,0 +1,44 @@
+package admission
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule_Current(t *testing.T) {
+	auditUntil := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	warnUntil := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		auditUntil time.Time
+		warnUntil  time.Time
+		now        time.Time
+		want       Phase
+	}{
+		{"before audit end", auditUntil, warnUntil, auditUntil.Add(-time.Hour), PhaseAudit},
+		{"at audit end", auditUntil, warnUntil, auditUntil, PhaseWarn},
+		{"before warn end", auditUntil, warnUntil, warnUntil.Add(-time.Second), PhaseWarn},
+		{"at warn end", auditUntil, warnUntil, warnUntil, PhaseEnforce},
+		{"no audit phase", time.Time{}, warnUntil, auditUntil, PhaseWarn},
+		{"no warn phase", auditUntil, time.Time{}, auditUntil.Add(time.Hour), PhaseEnforce},
+		{"empty schedule", time.Time{}, time.Time{}, auditUntil, PhaseEnforce},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSchedule(tt.auditUntil, tt.warnUntil, func() time.Time { return tt.now })
+			assert.Equal(t, tt.want, s.Current())
+		})
+	}
+}
+
+func TestSchedule_EnforceFrom(t *testing.T) {
+	auditUntil := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	warnUntil := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, warnUntil, NewSchedule(auditUntil, warnUntil, time.Now).EnforceFrom())
+	assert.Equal(t, auditUntil, NewSchedule(auditUntil, time.Time{}, time.Now).EnforceFrom())
+}
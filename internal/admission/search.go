@@ -0,0 +1,107 @@
+package admission
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+// SearchDomains is a PodMutator appending search domains to the DNS config
+// of the pods in the namespaces the namespace filter admits. It is
+// independent of the ndots policy: the opt-in/opt-out key does not apply.
+type SearchDomains struct {
+	domains         []string
+	namespaceFilter *NamespaceFilter
+}
+
+// NewSearchDomains creates a SearchDomains mutator for the search domains of
+// cfg.
+func NewSearchDomains(cfg *config.Config, logger *slog.Logger) *SearchDomains {
+	return &SearchDomains{
+		domains:         cfg.SearchDomains,
+		namespaceFilter: NewNamespaceFilter(cfg.NamespaceInclude, cfg.NamespaceExclude, logger),
+	}
+}
+
+// Mutate appends the search domains pod does not have yet.
+func (s *SearchDomains) Mutate(ctx context.Context, req Request, pod *corev1.Pod) (Decision, error) {
+	if err := ctx.Err(); err != nil {
+		return Decision{}, err
+	}
+
+	d, missing := s.scope(req.Namespace, pod)
+	if !d.Mutates() {
+		return d, nil
+	}
+
+	desired := pod.DeepCopy()
+	if desired.Spec.DNSConfig == nil {
+		desired.Spec.DNSConfig = &corev1.PodDNSConfig{}
+	}
+	desired.Spec.DNSConfig.Searches = append(desired.Spec.DNSConfig.Searches, missing...)
+	patch, err := CreatePatch(pod, desired)
+	if err != nil {
+		return Decision{}, newError(CodePatchBuild, err)
+	}
+	d.Patch = patch
+	return d, nil
+}
+
+// Prefilter skips pod if it is not mutated. The decision only depends on the
+// pod's DNS config. It implements PodPrefilter.
+func (s *SearchDomains) Prefilter(_ context.Context, req Request, pod *corev1.Pod) (Decision, bool) {
+	d, _ := s.scope(req.Namespace, pod)
+	return d, !d.Mutates()
+}
+
+// DecisionKey returns a hash of the search domains of pod. It implements
+// DecisionKeyer.
+func (s *SearchDomains) DecisionKey(req Request, pod *corev1.Pod) (string, bool) {
+	var searches []string
+	if pod.Spec.DNSConfig != nil {
+		searches = pod.Spec.DNSConfig.Searches
+	}
+	sum := sha256.Sum256([]byte(strings.Join(searches, " ")))
+	return req.Namespace + "/" + hex.EncodeToString(sum[:]), true
+}
+
+// scope returns the Decision for pod in namespace, without a patch, and the
+// search domains it is missing.
+func (s *SearchDomains) scope(namespace string, pod *corev1.Pod) (Decision, []string) {
+	ndots := currentNdots(pod)
+	switch s.namespaceFilter.rejection(namespace) {
+	case "":
+	case "excluded":
+		return skipDecision(ReasonNamespaceExcluded, "", "namespace exclude list", ndots), nil
+	default:
+		return skipDecision(ReasonNamespaceNotIncluded, "", "namespace include list", ndots), nil
+	}
+
+	var existing, missing []string
+	if pod.Spec.DNSConfig != nil {
+		existing = pod.Spec.DNSConfig.Searches
+	}
+	for _, domain := range s.domains {
+		if !slices.Contains(existing, domain) && !slices.Contains(missing, domain) {
+			missing = append(missing, domain)
+		}
+	}
+	if len(missing) == 0 {
+		return skipDecision(ReasonAlreadyCompliant, PolicyCluster, "search domains present", ndots), nil
+	}
+	return Decision{
+		Outcome:     OutcomeMutate,
+		Reason:      ReasonAlways,
+		Policy:      PolicyCluster,
+		Rule:        "search " + strings.Join(missing, " "),
+		NdotsBefore: ndots,
+		NdotsAfter:  ndots,
+	}, missing
+}
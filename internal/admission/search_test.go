@@ -0,0 +1,96 @@
+package admission
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestSearchDomains_Mutate(t *testing.T) {
+	s := NewSearchDomains(&config.Config{
+		SearchDomains:    []string{"corp.example.com", "example.com"},
+		NamespaceExclude: []string{"kube-system"},
+	}, slog.Default())
+	req := Request{Namespace: "shop"}
+
+	t.Run("no dns config", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+		d, err := s.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, OutcomeMutate, d.Outcome)
+		assert.Equal(t, ReasonAlways, d.Reason)
+		assert.Equal(t, "search corp.example.com example.com", d.Rule)
+		assert.Equal(t, 5, d.NdotsAfter)
+
+		patched, err := applyPatch(pod, d.Patch)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"corp.example.com", "example.com"}, patched.Spec.DNSConfig.Searches)
+		assert.Nil(t, patched.Spec.DNSConfig.Options)
+	})
+
+	t.Run("missing domains are appended", func(t *testing.T) {
+		pod := &corev1.Pod{Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Searches: []string{"example.com", "team.example.com"}}}}
+		d, err := s.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, "search corp.example.com", d.Rule)
+
+		patched, err := applyPatch(pod, d.Patch)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example.com", "team.example.com", "corp.example.com"}, patched.Spec.DNSConfig.Searches)
+	})
+
+	t.Run("domains present", func(t *testing.T) {
+		pod := &corev1.Pod{Spec: corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Searches: []string{"example.com", "corp.example.com"}}}}
+		d, err := s.Mutate(context.Background(), req, pod)
+		require.NoError(t, err)
+		assert.Equal(t, OutcomeSkip, d.Outcome)
+		assert.Equal(t, ReasonAlreadyCompliant, d.Reason)
+		assert.Empty(t, d.Patch)
+	})
+
+	t.Run("namespace excluded", func(t *testing.T) {
+		d, err := s.Mutate(context.Background(), Request{Namespace: "kube-system"}, &corev1.Pod{})
+		require.NoError(t, err)
+		assert.Equal(t, ReasonNamespaceExcluded, d.Reason)
+	})
+}
+
+func TestSearchDomains_Prefilter(t *testing.T) {
+	s := NewSearchDomains(&config.Config{SearchDomains: []string{"corp.example.com"}}, slog.Default())
+	req := Request{Namespace: "shop"}
+
+	_, ok := s.Prefilter(context.Background(), req, &corev1.Pod{})
+	assert.False(t, ok, "pods missing a domain need the full pod")
+
+	d, ok := s.Prefilter(context.Background(), req, &corev1.Pod{Spec: corev1.PodSpec{
+		DNSConfig: &corev1.PodDNSConfig{Searches: []string{"corp.example.com"}},
+	}})
+	assert.True(t, ok)
+	assert.Equal(t, ReasonAlreadyCompliant, d.Reason)
+}
+
+func TestSearchDomains_DecisionKey(t *testing.T) {
+	s := NewSearchDomains(&config.Config{SearchDomains: []string{"corp.example.com"}}, slog.Default())
+	withSearches := func(searches ...string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{DNSConfig: &corev1.PodDNSConfig{Searches: searches}},
+		}
+	}
+
+	a, ok := s.DecisionKey(Request{Namespace: "shop"}, withSearches("example.com"))
+	require.True(t, ok)
+	b, _ := s.DecisionKey(Request{Namespace: "shop"}, withSearches("example.com"))
+	c, _ := s.DecisionKey(Request{Namespace: "shop"}, withSearches("corp.example.com"))
+	d, _ := s.DecisionKey(Request{Namespace: "payments"}, withSearches("example.com"))
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.NotEqual(t, a, d)
+}
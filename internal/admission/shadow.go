@@ -0,0 +1,96 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync/atomic"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Shadow evaluation results, used as the metrics label.
+const (
+	ShadowMatch          = "match"
+	ShadowWouldMutate    = "would-mutate"
+	ShadowWouldSkip      = "would-skip"
+	ShadowDifferentPatch = "different-patch"
+	ShadowError          = "error"
+)
+
+// Shadow evaluates a candidate policy next to the active one without
+// affecting admission responses.
+type Shadow struct {
+	mutator  PodMutator
+	logEvery uint64
+	seen     atomic.Uint64
+	logger   *slog.Logger
+}
+
+// NewShadow creates a Shadow for the candidate mutator. One in logEvery
+// divergences is logged; values below 1 log every divergence.
+func NewShadow(mutator PodMutator, logEvery int, logger *slog.Logger) *Shadow {
+	if logEvery < 1 {
+		logEvery = 1
+	}
+	return &Shadow{
+		mutator:  mutator,
+		logEvery: uint64(logEvery),
+		logger:   logger,
+	}
+}
+
+// Evaluate runs the candidate policy on pod for req and compares its patch
+// with the active patch. It returns one of the Shadow* results.
+func (s *Shadow) Evaluate(ctx context.Context, req Request, pod *corev1.Pod, active []PatchOperation) string {
+	namespace := req.Namespace
+	candidate, err := s.mutator.Mutate(ctx, req, pod.DeepCopy())
+	if err != nil {
+		s.logger.Warn("shadow evaluation failed",
+			"namespace", namespace,
+			"name", getPodName(pod),
+			"workload", WorkloadOf(pod).String(),
+			"error", err,
+		)
+		return ShadowError
+	}
+
+	result := compareShadow(active, candidate.Patch)
+	if result != ShadowMatch && (s.seen.Add(1)-1)%s.logEvery == 0 {
+		s.logger.Info("shadow policy diverges",
+			"namespace", namespace,
+			"name", getPodName(pod),
+			"workload", WorkloadOf(pod).String(),
+			"result", result,
+			"activePatch", active,
+			"candidatePatch", candidate.Patch,
+			"candidateReason", candidate.Reason,
+		)
+	}
+	return result
+}
+
+func compareShadow(active, candidate []PatchOperation) string {
+	switch {
+	case active == nil && candidate == nil:
+		return ShadowMatch
+	case active == nil:
+		return ShadowWouldMutate
+	case candidate == nil:
+		return ShadowWouldSkip
+	}
+
+	activeBytes, err := json.Marshal(active)
+	if err != nil {
+		return ShadowError
+	}
+	candidateBytes, err := json.Marshal(candidate)
+	if err != nil {
+		return ShadowError
+	}
+	if !bytes.Equal(activeBytes, candidateBytes) {
+		return ShadowDifferentPatch
+	}
+	return ShadowMatch
+}
@@ -0,0 +1,91 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestShadow_Evaluate(t *testing.T) {
+	active := &config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"}
+
+	tests := []struct {
+		name      string
+		candidate *config.Config
+		pod       *corev1.Pod
+		want      string
+	}{
+		{
+			name:      "same policy",
+			candidate: active,
+			pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			want:      ShadowMatch,
+		},
+		{
+			name:      "different ndots value",
+			candidate: &config.Config{NdotsValue: 1, AnnotationKey: "change-ndots", AnnotationMode: "opt-out"},
+			pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			want:      ShadowDifferentPatch,
+		},
+		{
+			name:      "candidate opt-in skips",
+			candidate: &config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "opt-in"},
+			pod:       &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			want:      ShadowWouldSkip,
+		},
+		{
+			name:      "candidate always mutates opted-out pod",
+			candidate: &config.Config{NdotsValue: 2, AnnotationKey: "change-ndots", AnnotationMode: "always"},
+			pod: &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Annotations: map[string]string{"change-ndots": "false"},
+			}},
+			want: ShadowWouldMutate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activeDecision, err := NewMutator(active, slog.Default()).Mutate(context.Background(), Request{Namespace: "default"}, tt.pod)
+			assert.NoError(t, err)
+
+			shadow := NewShadow(NewMutator(tt.candidate, slog.Default()), 1, slog.Default())
+			assert.Equal(t, tt.want, shadow.Evaluate(context.Background(), Request{Namespace: "default"}, tt.pod, activeDecision.Patch))
+		})
+	}
+}
+
+func TestShadow_Evaluate_Error(t *testing.T) {
+	candidate := new(MockMutator)
+	candidate.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(Decision{}, errors.New("boom"))
+
+	shadow := NewShadow(candidate, 1, slog.Default())
+	assert.Equal(t, ShadowError, shadow.Evaluate(context.Background(), Request{Namespace: "default"}, &corev1.Pod{}, nil))
+}
+
+func TestShadow_LogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	candidate := new(MockMutator)
+	candidate.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
+		mutateDecision(PatchOperation{Op: "add", Path: "/spec/dnsConfig", Value: "x"}), nil,
+	)
+
+	shadow := NewShadow(candidate, 3, logger)
+	for i := 0; i < 7; i++ {
+		assert.Equal(t, ShadowWouldMutate, shadow.Evaluate(context.Background(), Request{Namespace: "default"}, &corev1.Pod{}, nil))
+	}
+
+	assert.Equal(t, 3, strings.Count(buf.String(), "shadow policy diverges"))
+}
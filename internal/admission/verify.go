@@ -0,0 +1,103 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// SetTestOperations guards every value a patch replaces or removes with an
+// RFC 6902 test operation, so the API server rejects the patch instead of
+// overwriting a value other than the one it was built against. The API server
+// applies the patch to the object the webhook received, so this only guards
+// the patch against mistakes in building it, not against other webhooks.
+func (h *Handler) SetTestOperations(enabled bool) {
+	h.testOperations = enabled
+}
+
+// verifyPatch applies patch to the submitted object raw, as the API server
+// will, and checks that the result decodes as a Pod with the ndots value of
+// d. If d does not change ndots, the value of pod must be kept.
+func verifyPatch(raw, patch []byte, pod *corev1.Pod, d Decision) error {
+	decoded, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return fmt.Errorf("decode patch: %w", err)
+	}
+	patched, err := decoded.Apply(raw)
+	if err != nil {
+		return fmt.Errorf("apply patch: %w", err)
+	}
+
+	var out corev1.Pod
+	if err := json.Unmarshal(patched, &out); err != nil {
+		return fmt.Errorf("decode patched pod: %w", err)
+	}
+	want := currentNdots(pod)
+	if d.NdotsAfter != d.NdotsBefore {
+		want = d.NdotsAfter
+	}
+	if n := currentNdots(&out); n != want {
+		return fmt.Errorf("patched pod has ndots %d, want %d", n, want)
+	}
+	return nil
+}
+
+// testOperations returns patch with a test operation before every replace
+// and remove, asserting the value the operation overwrites in raw. The values
+// are read from raw as submitted, which matches the document the operation
+// applies to because patches from CreatePatch never move a value before
+// overwriting it.
+func testOperations(raw []byte, patch []PatchOperation) ([]PatchOperation, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("decode object: %w", err)
+	}
+
+	out := make([]PatchOperation, 0, 2*len(patch))
+	for _, op := range patch {
+		if op.Op == "replace" || op.Op == "remove" {
+			value, ok := pointerValue(doc, op.Path)
+			if !ok {
+				return nil, fmt.Errorf("%s %s: no value at path", op.Op, op.Path)
+			}
+			out = append(out, PatchOperation{Op: "test", Path: op.Path, Value: value})
+		}
+		out = append(out, op)
+	}
+	return out, nil
+}
+
+// pointerValue returns the value at the JSON pointer (RFC 6901) path in doc.
+func pointerValue(doc interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+
+	for _, token := range strings.Split(path[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
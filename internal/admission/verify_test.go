@@ -0,0 +1,157 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestVerifyPatch(t *testing.T) {
+	raw := []byte(`{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}],"dnsConfig":{"options":[{"name":"ndots","value":"5"}]}}}`)
+	var pod corev1.Pod
+	require.NoError(t, json.Unmarshal(raw, &pod))
+	setTo2 := Decision{Outcome: OutcomeMutate, NdotsBefore: 5, NdotsAfter: 2}
+
+	tests := []struct {
+		name     string
+		patch    string
+		decision Decision
+		wantErr  string
+	}{
+		{
+			name:     "sets ndots",
+			patch:    `[{"op":"replace","path":"/spec/dnsConfig/options/0/value","value":"2"}]`,
+			decision: setTo2,
+		},
+		{
+			name:     "keeps ndots",
+			patch:    `[{"op":"add","path":"/spec/containers/0/env","value":[{"name":"A","value":"b"}]}]`,
+			decision: Decision{Outcome: OutcomeMutate, NdotsBefore: 5, NdotsAfter: 5},
+		},
+		{
+			name:     "missing path",
+			patch:    `[{"op":"replace","path":"/spec/dnsConfig/options/3/value","value":"2"}]`,
+			decision: setTo2,
+			wantErr:  "apply patch",
+		},
+		{
+			name:     "not a pod",
+			patch:    `[{"op":"replace","path":"/spec/containers","value":"app"}]`,
+			decision: setTo2,
+			wantErr:  "decode patched pod",
+		},
+		{
+			name:     "wrong ndots",
+			patch:    `[{"op":"replace","path":"/spec/dnsConfig/options/0/value","value":"3"}]`,
+			decision: setTo2,
+			wantErr:  "patched pod has ndots 3, want 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPatch(raw, []byte(tt.patch), &pod, tt.decision)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestTestOperations(t *testing.T) {
+	raw := []byte(`{"metadata":{"annotations":{"example.com/a~b":"1"}},"spec":{"containers":[{"name":"app","env":[{"name":"URL","value":"http://orders.shop"}]}]}}`)
+
+	t.Run("guards replaced and removed values", func(t *testing.T) {
+		patch, err := testOperations(raw, []PatchOperation{
+			{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}},
+			{Op: "replace", Path: "/spec/containers/0/env/0/value", Value: "http://orders.shop.svc.cluster.local."},
+			{Op: "remove", Path: "/metadata/annotations/example.com~1a~0b"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []PatchOperation{
+			{Op: "add", Path: "/spec/dnsConfig", Value: map[string]interface{}{}},
+			{Op: "test", Path: "/spec/containers/0/env/0/value", Value: "http://orders.shop"},
+			{Op: "replace", Path: "/spec/containers/0/env/0/value", Value: "http://orders.shop.svc.cluster.local."},
+			{Op: "test", Path: "/metadata/annotations/example.com~1a~0b", Value: "1"},
+			{Op: "remove", Path: "/metadata/annotations/example.com~1a~0b"},
+		}, patch)
+	})
+
+	t.Run("missing value", func(t *testing.T) {
+		_, err := testOperations(raw, []PatchOperation{{Op: "replace", Path: "/spec/containers/1/env/0/value", Value: "x"}})
+		assert.EqualError(t, err, "replace /spec/containers/1/env/0/value: no value at path")
+	})
+}
+
+func TestHandler_PatchVerification(t *testing.T) {
+	mutate := func(h *Handler, pod string) *admissionv1.AdmissionResponse {
+		review := createValidAdmissionReview("web", "default")
+		review.Request.Object.Raw = []byte(pod)
+		body, _ := json.Marshal(review)
+		w := httptest.NewRecorder()
+		h.HandleMutate(w, httptest.NewRequest("POST", "/mutate", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp admissionv1.AdmissionReview
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.Response
+	}
+
+	t.Run("patch that does not apply", func(t *testing.T) {
+		mockMutator := new(MockMutator)
+		mockMutator.On("Mutate", mock.AnythingOfType("*v1.Pod")).Return(
+			mutateDecision(PatchOperation{Op: "replace", Path: "/spec/dnsConfig/options/0/value", Value: "2"}), nil,
+		)
+		mockMetrics := new(MockMetricsRecorder)
+		mockMetrics.On("ObserveRequestDuration", mock.AnythingOfType("float64")).Once()
+		mockMetrics.On("RecordError", CodePatchVerify).Once()
+		mockMetrics.On("RecordFailure", CodePatchVerify, "allowed").Once()
+		ring := NewDecisionRing(10)
+		h := NewHandlerWithMetrics(mockMutator, slog.Default(), mockMetrics)
+		h.SetDecisionRing(ring)
+
+		resp := mutate(h, `{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app"}]}}`)
+		assert.True(t, resp.Allowed)
+		assert.Empty(t, resp.Patch)
+		mockMetrics.AssertExpectations(t)
+
+		records := ring.List("", "", 0)
+		require.Len(t, records, 1)
+		assert.Equal(t, "error", records[0].Decision)
+		assert.Contains(t, records[0].Rule, "apply patch")
+	})
+
+	t.Run("test operations", func(t *testing.T) {
+		mutator := NewMutator(&config.Config{
+			NdotsValue:            2,
+			ClusterDomain:         "cluster.local",
+			FQDNRewriteNamespaces: []string{"default"},
+		}, slog.Default())
+		h := NewHandler(mutator, slog.Default())
+		h.SetTestOperations(true)
+
+		resp := mutate(h, `{"metadata":{"name":"web"},"spec":{"containers":[{"name":"app","env":[{"name":"URL","value":"http://orders.default:8080"}]}],`+
+			`"dnsConfig":{"options":[{"name":"ndots","value":"5"}]}}}`)
+		assert.True(t, resp.Allowed)
+		assert.JSONEq(t, `[
+			{"op":"test","path":"/spec/containers/0/env/0/value","value":"http://orders.default:8080"},
+			{"op":"replace","path":"/spec/containers/0/env/0/value","value":"http://orders.default.svc.cluster.local.:8080"},
+			{"op":"test","path":"/spec/dnsConfig/options/0/value","value":"5"},
+			{"op":"replace","path":"/spec/dnsConfig/options/0/value","value":"2"}
+		]`, string(resp.Patch))
+	})
+}
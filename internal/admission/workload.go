@@ -0,0 +1,60 @@
+package admission
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workload identifies the top-level object that created a pod.
+type Workload struct {
+	Kind string
+	Name string
+}
+
+func (w Workload) String() string {
+	return w.Kind + "/" + w.Name
+}
+
+// minCronJobSuffixDigits is the length of the scheduled-time suffix, in
+// minutes since the epoch, that the CronJob controller appends to job names.
+const minCronJobSuffixDigits = 8
+
+// WorkloadOf returns the top-level workload of pod. It is derived from the
+// pod's controller reference and the naming conventions of the built-in
+// controllers, without API calls: a ReplicaSet named after the pod's
+// pod-template-hash belongs to a Deployment, and a Job with a scheduled-time
+// suffix to a CronJob. Pods without a controller are their own workload.
+func WorkloadOf(pod *corev1.Pod) Workload {
+	ref := metav1.GetControllerOfNoCopy(pod)
+	if ref == nil {
+		return Workload{Kind: "Pod", Name: getPodName(pod)}
+	}
+
+	switch {
+	case ref.Kind == "ReplicaSet" && strings.HasPrefix(ref.APIVersion, "apps/"):
+		if hash := pod.Labels["pod-template-hash"]; hash != "" {
+			if name, ok := strings.CutSuffix(ref.Name, "-"+hash); ok {
+				return Workload{Kind: "Deployment", Name: name}
+			}
+		}
+	case ref.Kind == "Job" && strings.HasPrefix(ref.APIVersion, "batch/"):
+		if i := strings.LastIndexByte(ref.Name, '-'); i > 0 && isCronJobSuffix(ref.Name[i+1:]) {
+			return Workload{Kind: "CronJob", Name: ref.Name[:i]}
+		}
+	}
+	return Workload{Kind: ref.Kind, Name: ref.Name}
+}
+
+func isCronJobSuffix(s string) bool {
+	if len(s) < minCronJobSuffixDigits {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
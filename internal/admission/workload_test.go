@@ -0,0 +1,43 @@
+package admission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkloadOf(t *testing.T) {
+	controlledBy := func(apiVersion, kind, name string, labels map[string]string) *corev1.Pod {
+		controller := true
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			GenerateName: name + "-",
+			Labels:       labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: apiVersion, Kind: kind, Name: name, Controller: &controller,
+			}},
+		}}
+	}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want Workload
+	}{
+		{"bare pod", &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug"}}, Workload{"Pod", "debug"}},
+		{"deployment", controlledBy("apps/v1", "ReplicaSet", "web-7d9f8c6b5", map[string]string{"pod-template-hash": "7d9f8c6b5"}), Workload{"Deployment", "web"}},
+		{"replicaset without hash", controlledBy("apps/v1", "ReplicaSet", "web-7d9f8c6b5", nil), Workload{"ReplicaSet", "web-7d9f8c6b5"}},
+		{"replicaset with other hash", controlledBy("apps/v1", "ReplicaSet", "web", map[string]string{"pod-template-hash": "7d9f8c6b5"}), Workload{"ReplicaSet", "web"}},
+		{"cronjob", controlledBy("batch/v1", "Job", "report-29150400", nil), Workload{"CronJob", "report"}},
+		{"job", controlledBy("batch/v1", "Job", "migrate-2", nil), Workload{"Job", "migrate-2"}},
+		{"statefulset", controlledBy("apps/v1", "StatefulSet", "db", nil), Workload{"StatefulSet", "db"}},
+		{"custom kind", controlledBy("example.com/v1", "Job", "report-29150400", nil), Workload{"Job", "report-29150400"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, WorkloadOf(tt.pod))
+		})
+	}
+}
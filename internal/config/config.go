@@ -2,40 +2,93 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/expression"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/owner"
 )
 
 type Config struct {
-	NdotsValue       int
-	AnnotationKey    string
-	AnnotationMode   string
-	NamespaceInclude []string
-	NamespaceExclude []string
-	Port             int
-	TLSCertPath      string
-	TLSKeyPath       string
-	Timeout          time.Duration
-	LogLevel         string
-	LogFormat        string
-	MetricsPort      int
+	NdotsValue               int
+	AnnotationKey            string
+	AnnotationKeyAliases     []string
+	AnnotationMode           string
+	AnnotationSource         string
+	NamespaceInclude         []string
+	NamespaceExclude         []string
+	Port                     int
+	TLSCertPath              string
+	TLSKeyPath               string
+	Timeout                  time.Duration
+	LogLevel                 string
+	LogFormat                string
+	MetricsPort              int
+	MetricsWorkloadLabel     bool
+	ClusterDomain            string
+	FQDNRewriteNamespaces    []string
+	SearchDomains            []string
+	AuditUntil               time.Time
+	WarnUntil                time.Time
+	ShadowLogEvery           int
+	InheritFromOwner         bool
+	OwnerLookupDepth         int
+	OwnerResources           []string
+	CacheSyncTimeout         time.Duration
+	TenantPolicyEnabled      bool
+	TenantPolicyConfigMap    string
+	TenantAllowedModes       []string
+	TenantNdotsMin           int
+	TenantNdotsMax           int
+	EventsEnabled            bool
+	PolicyReportEnabled      bool
+	PolicyReportWriteQPS     int
+	PolicyReportResync       time.Duration
+	ComplianceMetricsEnabled bool
+	ExplainEnabled           bool
+	DebugToken               string
+	DecisionBufferSize       int
+	MutationCondition        string
+	NdotsExpression          string
+	ExpressionCostLimit      uint64
+	PatchTestOperations      bool
+	FailureMode              string
+	FailureModeNamespaces    map[string]string
+	DecisionCacheSize        int
 }
 
 var DefaultConfig = Config{
-	Port:             8443,
-	NdotsValue:       2,
-	AnnotationKey:    "change-ndots",
-	AnnotationMode:   "opt-out",
-	NamespaceExclude: []string{"kube-system", "kube-public", "kube-node-lease"},
-	Timeout:          10 * time.Second,
-	TLSCertPath:      "/certs/tls.crt",
-	TLSKeyPath:       "/certs/tls.key",
-	LogLevel:         "info",
-	LogFormat:        "json",
-	MetricsPort:      8080,
+	Port:                  8443,
+	NdotsValue:            2,
+	AnnotationKey:         "change-ndots",
+	AnnotationMode:        "opt-out",
+	AnnotationSource:      "annotation",
+	NamespaceExclude:      []string{"kube-system", "kube-public", "kube-node-lease"},
+	Timeout:               10 * time.Second,
+	TLSCertPath:           "/certs/tls.crt",
+	TLSKeyPath:            "/certs/tls.key",
+	LogLevel:              "info",
+	LogFormat:             "json",
+	MetricsPort:           8080,
+	ClusterDomain:         "cluster.local",
+	ShadowLogEvery:        100,
+	OwnerLookupDepth:      2,
+	CacheSyncTimeout:      30 * time.Second,
+	TenantPolicyConfigMap: "ndots-policy",
+	TenantAllowedModes:    []string{"always", "opt-in", "opt-out"},
+	TenantNdotsMin:        1,
+	TenantNdotsMax:        5,
+	PolicyReportWriteQPS:  5,
+	PolicyReportResync:    10 * time.Minute,
+	DecisionBufferSize:    500,
+	ExpressionCostLimit:   expression.DefaultCostLimit,
+	FailureMode:           "allow",
 }
 
 func Load() (*Config, error) {
@@ -46,23 +99,7 @@ func Load() (*Config, error) {
 			cfg.Port = port
 		}
 	}
-	if v := os.Getenv("NDOTS_VALUE"); v != "" {
-		if ndots, err := strconv.Atoi(v); err == nil {
-			cfg.NdotsValue = ndots
-		}
-	}
-	if v := os.Getenv("ANNOTATION_KEY"); v != "" {
-		cfg.AnnotationKey = v
-	}
-	if v := os.Getenv("ANNOTATION_MODE"); v != "" {
-		cfg.AnnotationMode = v
-	}
-	if v := os.Getenv("NAMESPACE_INCLUDE"); v != "" {
-		cfg.NamespaceInclude = splitAndTrim(v)
-	}
-	if v := os.Getenv("NAMESPACE_EXCLUDE"); v != "" {
-		cfg.NamespaceExclude = splitAndTrim(v)
-	}
+	applyPolicyEnv(&cfg, "")
 	if v := os.Getenv("TLS_CERT_PATH"); v != "" {
 		cfg.TLSCertPath = v
 	}
@@ -80,6 +117,148 @@ func Load() (*Config, error) {
 			cfg.MetricsPort = port
 		}
 	}
+	if v := os.Getenv("METRICS_WORKLOAD_LABEL"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.MetricsWorkloadLabel = b
+		}
+	}
+
+	if v := os.Getenv("CLUSTER_DOMAIN"); v != "" {
+		cfg.ClusterDomain = strings.Trim(v, ".")
+	}
+	if v := os.Getenv("SHADOW_LOG_EVERY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ShadowLogEvery = n
+		}
+	}
+
+	if v := os.Getenv("INHERIT_FROM_OWNER"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.InheritFromOwner = b
+		}
+	}
+	if v := os.Getenv("OWNER_LOOKUP_DEPTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.OwnerLookupDepth = n
+		}
+	}
+	if v := os.Getenv("OWNER_RESOURCES"); v != "" {
+		cfg.OwnerResources = splitAndTrim(v)
+	}
+	if v := os.Getenv("CACHE_SYNC_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.CacheSyncTimeout = d
+		}
+	}
+
+	if v := os.Getenv("TENANT_POLICY_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.TenantPolicyEnabled = b
+		}
+	}
+	if v := os.Getenv("TENANT_POLICY_CONFIGMAP"); v != "" {
+		cfg.TenantPolicyConfigMap = v
+	}
+	if v := os.Getenv("TENANT_ALLOWED_MODES"); v != "" {
+		cfg.TenantAllowedModes = splitAndTrim(v)
+	}
+	if v := os.Getenv("TENANT_NDOTS_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.TenantNdotsMin = n
+		}
+	}
+	if v := os.Getenv("TENANT_NDOTS_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.TenantNdotsMax = n
+		}
+	}
+
+	if v := os.Getenv("EVENTS_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.EventsEnabled = b
+		}
+	}
+
+	if v := os.Getenv("POLICY_REPORT_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.PolicyReportEnabled = b
+		}
+	}
+	if v := os.Getenv("POLICY_REPORT_WRITE_QPS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.PolicyReportWriteQPS = n
+		}
+	}
+	if v := os.Getenv("POLICY_REPORT_RESYNC"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PolicyReportResync = d
+		}
+	}
+
+	if v := os.Getenv("COMPLIANCE_METRICS_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.ComplianceMetricsEnabled = b
+		}
+	}
+
+	if v := os.Getenv("EXPLAIN_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.ExplainEnabled = b
+		}
+	}
+
+	if v := os.Getenv("DEBUG_TOKEN"); v != "" {
+		cfg.DebugToken = v
+	}
+	if v := os.Getenv("DECISION_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.DecisionBufferSize = n
+		}
+	}
+
+	if v := os.Getenv("EXPRESSION_COST_LIMIT"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+			cfg.ExpressionCostLimit = n
+		}
+	}
+
+	if v := os.Getenv("PATCH_TEST_OPERATIONS"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			cfg.PatchTestOperations = b
+		}
+	}
+
+	if v := os.Getenv("DECISION_CACHE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.DecisionCacheSize = n
+		}
+	}
+
+	if v := os.Getenv("FAILURE_MODE"); v != "" {
+		cfg.FailureMode = v
+	}
+	if v := os.Getenv("FAILURE_MODE_NAMESPACES"); v != "" {
+		modes, err := splitPairs(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FAILURE_MODE_NAMESPACES: %w", err)
+		}
+		cfg.FailureModeNamespaces = modes
+	}
+
+	if v := os.Getenv("ENFORCEMENT_AUDIT_UNTIL"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENFORCEMENT_AUDIT_UNTIL: %w", err)
+		}
+		cfg.AuditUntil = t
+	}
+	if v := os.Getenv("ENFORCEMENT_WARN_UNTIL"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENFORCEMENT_WARN_UNTIL: %w", err)
+		}
+		cfg.WarnUntil = t
+	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -87,6 +266,75 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
+// CandidatePrefix prefixes the environment variables of the candidate policy.
+const CandidatePrefix = "CANDIDATE_"
+
+// LoadCandidate loads the optional candidate policy that is evaluated in
+// shadow next to active. It starts from active and applies the policy
+// variables prefixed with CandidatePrefix, e.g. CANDIDATE_NDOTS_VALUE. It
+// returns nil if no candidate variable is set.
+func LoadCandidate(active *Config) (*Config, error) {
+	cfg := *active
+	if !applyPolicyEnv(&cfg, CandidatePrefix) {
+		return nil, nil
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid candidate policy: %w", err)
+	}
+	return &cfg, nil
+}
+
+// applyPolicyEnv applies the environment variables that make up the mutation
+// policy, each prefixed with prefix. It reports whether any was set.
+func applyPolicyEnv(cfg *Config, prefix string) bool {
+	found := false
+	getenv := func(key string) string {
+		v := os.Getenv(prefix + key)
+		if v != "" {
+			found = true
+		}
+		return v
+	}
+
+	if v := getenv("NDOTS_VALUE"); v != "" {
+		if ndots, err := strconv.Atoi(v); err == nil {
+			cfg.NdotsValue = ndots
+		}
+	}
+	if v := getenv("ANNOTATION_KEY"); v != "" {
+		cfg.AnnotationKey = v
+	}
+	if v := getenv("ANNOTATION_KEY_ALIASES"); v != "" {
+		cfg.AnnotationKeyAliases = splitAndTrim(v)
+	}
+	if v := getenv("ANNOTATION_MODE"); v != "" {
+		cfg.AnnotationMode = v
+	}
+	if v := getenv("ANNOTATION_SOURCE"); v != "" {
+		cfg.AnnotationSource = v
+	}
+	if v := getenv("NAMESPACE_INCLUDE"); v != "" {
+		cfg.NamespaceInclude = splitAndTrim(v)
+	}
+	if v := getenv("NAMESPACE_EXCLUDE"); v != "" {
+		cfg.NamespaceExclude = splitAndTrim(v)
+	}
+	if v := getenv("FQDN_REWRITE_NAMESPACES"); v != "" {
+		cfg.FQDNRewriteNamespaces = splitAndTrim(v)
+	}
+	if v := getenv("SEARCH_DOMAINS"); v != "" {
+		cfg.SearchDomains = splitAndTrim(v)
+	}
+	if v := getenv("MUTATION_CONDITION"); v != "" {
+		cfg.MutationCondition = v
+	}
+	if v := getenv("NDOTS_EXPRESSION"); v != "" {
+		cfg.NdotsExpression = v
+	}
+	return found
+}
+
 func (c *Config) Validate() error {
 	if c.Port < 1 || c.Port > 65535 {
 		return errors.New("port must be between 1 and 65535")
@@ -95,11 +343,28 @@ func (c *Config) Validate() error {
 		return errors.New("ndotsValue must be between 0 and 15")
 	}
 
+	if errs := validation.IsQualifiedName(c.AnnotationKey); len(errs) > 0 {
+		return fmt.Errorf("annotationKey %q is invalid: %s", c.AnnotationKey, strings.Join(errs, "; "))
+	}
+	for _, alias := range c.AnnotationKeyAliases {
+		if errs := validation.IsQualifiedName(alias); len(errs) > 0 {
+			return fmt.Errorf("annotationKeyAliases contains invalid key %q: %s", alias, strings.Join(errs, "; "))
+		}
+		if alias == c.AnnotationKey {
+			return errors.New("annotationKeyAliases must not contain annotationKey")
+		}
+	}
+
 	validModes := map[string]bool{"always": true, "opt-in": true, "opt-out": true}
 	if !validModes[c.AnnotationMode] {
 		return errors.New("annotationMode must be 'always', 'opt-in', or 'opt-out'")
 	}
 
+	validSources := map[string]bool{"": true, "annotation": true, "label": true, "either": true}
+	if !validSources[c.AnnotationSource] {
+		return errors.New("annotationSource must be 'annotation', 'label', or 'either'")
+	}
+
 	if c.TLSCertPath == "" {
 		return errors.New("tlsCertPath is required")
 	}
@@ -107,14 +372,100 @@ func (c *Config) Validate() error {
 		return errors.New("tlsKeyPath is required")
 	}
 
+	if len(c.FQDNRewriteNamespaces) > 0 && c.ClusterDomain == "" {
+		return errors.New("clusterDomain is required when fqdnRewriteNamespaces is set")
+	}
+	for _, domain := range c.SearchDomains {
+		if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+			return fmt.Errorf("searchDomains contains invalid domain %q: %s", domain, strings.Join(errs, "; "))
+		}
+	}
+
+	if c.InheritFromOwner && (c.OwnerLookupDepth < 1 || c.OwnerLookupDepth > 5) {
+		return errors.New("ownerLookupDepth must be between 1 and 5")
+	}
+	if _, err := owner.ParseResources(c.OwnerResources); err != nil {
+		return fmt.Errorf("invalid ownerResources: %w", err)
+	}
+
+	if c.TenantPolicyEnabled {
+		if c.TenantPolicyConfigMap == "" {
+			return errors.New("tenantPolicyConfigMap is required when tenant policies are enabled")
+		}
+		if c.TenantNdotsMin < 0 || c.TenantNdotsMax > 15 || c.TenantNdotsMin > c.TenantNdotsMax {
+			return errors.New("tenantNdotsMin and tenantNdotsMax must form a range within 0 and 15")
+		}
+		for _, mode := range c.TenantAllowedModes {
+			if !validModes[mode] {
+				return fmt.Errorf("tenantAllowedModes contains invalid mode %q", mode)
+			}
+		}
+	}
+
+	validFailureModes := map[string]bool{"": true, "allow": true, "deny": true}
+	if !validFailureModes[c.FailureMode] {
+		return errors.New("failureMode must be 'allow' or 'deny'")
+	}
+	for ns, mode := range c.FailureModeNamespaces {
+		if mode == "" || !validFailureModes[mode] {
+			return fmt.Errorf("failureModeNamespaces has invalid mode %q for namespace %q", mode, ns)
+		}
+	}
+
+	if c.PolicyReportEnabled && c.PolicyReportWriteQPS < 1 {
+		return errors.New("policyReportWriteQPS must be at least 1")
+	}
+
+	if c.DebugToken != "" && c.DecisionBufferSize < 1 {
+		return errors.New("decisionBufferSize must be at least 1")
+	}
+
+	if c.DecisionCacheSize < 0 {
+		return errors.New("decisionCacheSize must not be negative")
+	}
+
+	if c.MutationCondition != "" || c.NdotsExpression != "" {
+		if c.ExpressionCostLimit < 1 {
+			return errors.New("expressionCostLimit must be at least 1")
+		}
+		if _, err := c.CompileCondition(); err != nil {
+			return fmt.Errorf("mutationCondition is invalid: %w", err)
+		}
+		if _, err := c.CompileNdotsExpression(); err != nil {
+			return fmt.Errorf("ndotsExpression is invalid: %w", err)
+		}
+	}
+
+	if !c.AuditUntil.IsZero() && !c.WarnUntil.IsZero() && c.WarnUntil.Before(c.AuditUntil) {
+		return errors.New("warnUntil must not be before auditUntil")
+	}
+
 	return nil
 }
 
+// CompileCondition compiles MutationCondition. It returns nil if none is set.
+func (c *Config) CompileCondition() (*expression.Expression, error) {
+	if c.MutationCondition == "" {
+		return nil, nil
+	}
+	return expression.Compile(c.MutationCondition, expression.Bool, c.ExpressionCostLimit)
+}
+
+// CompileNdotsExpression compiles NdotsExpression. It returns nil if none is set.
+func (c *Config) CompileNdotsExpression() (*expression.Expression, error) {
+	if c.NdotsExpression == "" {
+		return nil, nil
+	}
+	return expression.Compile(c.NdotsExpression, expression.Int, c.ExpressionCostLimit)
+}
+
 func (c *Config) LogValue() slog.Value {
 	return slog.GroupValue(
 		slog.Int("ndotsValue", c.NdotsValue),
 		slog.String("annotationKey", c.AnnotationKey),
+		slog.Any("annotationKeyAliases", c.AnnotationKeyAliases),
 		slog.String("annotationMode", c.AnnotationMode),
+		slog.String("annotationSource", c.AnnotationSource),
 		slog.Any("namespaceInclude", c.NamespaceInclude),
 		slog.Any("namespaceExclude", c.NamespaceExclude),
 		slog.Int("port", c.Port),
@@ -124,9 +475,50 @@ func (c *Config) LogValue() slog.Value {
 		slog.String("logLevel", c.LogLevel),
 		slog.String("logFormat", c.LogFormat),
 		slog.Int("metricsPort", c.MetricsPort),
+		slog.Bool("metricsWorkloadLabel", c.MetricsWorkloadLabel),
+		slog.String("clusterDomain", c.ClusterDomain),
+		slog.Any("fqdnRewriteNamespaces", c.FQDNRewriteNamespaces),
+		slog.Any("searchDomains", c.SearchDomains),
+		slog.Time("auditUntil", c.AuditUntil),
+		slog.Time("warnUntil", c.WarnUntil),
+		slog.Int("shadowLogEvery", c.ShadowLogEvery),
+		slog.Bool("inheritFromOwner", c.InheritFromOwner),
+		slog.Int("ownerLookupDepth", c.OwnerLookupDepth),
+		slog.Any("ownerResources", c.OwnerResources),
+		slog.String("cacheSyncTimeout", c.CacheSyncTimeout.String()),
+		slog.Bool("tenantPolicyEnabled", c.TenantPolicyEnabled),
+		slog.String("tenantPolicyConfigMap", c.TenantPolicyConfigMap),
+		slog.Any("tenantAllowedModes", c.TenantAllowedModes),
+		slog.Int("tenantNdotsMin", c.TenantNdotsMin),
+		slog.Int("tenantNdotsMax", c.TenantNdotsMax),
+		slog.Bool("eventsEnabled", c.EventsEnabled),
+		slog.Bool("policyReportEnabled", c.PolicyReportEnabled),
+		slog.Int("policyReportWriteQPS", c.PolicyReportWriteQPS),
+		slog.String("policyReportResync", c.PolicyReportResync.String()),
+		slog.Bool("complianceMetricsEnabled", c.ComplianceMetricsEnabled),
+		slog.Bool("explainEnabled", c.ExplainEnabled),
+		// The token itself is a secret.
+		slog.Bool("debugEndpoints", c.DebugToken != ""),
+		slog.Int("decisionBufferSize", c.DecisionBufferSize),
+		slog.String("mutationCondition", c.MutationCondition),
+		slog.String("ndotsExpression", c.NdotsExpression),
+		slog.Uint64("expressionCostLimit", c.ExpressionCostLimit),
+		slog.Bool("patchTestOperations", c.PatchTestOperations),
+		slog.String("failureMode", c.FailureMode),
+		slog.Any("failureModeNamespaces", c.FailureModeNamespaces),
+		slog.Int("decisionCacheSize", c.DecisionCacheSize),
 	)
 }
 
+// parseTime accepts an RFC 3339 timestamp or a plain date, which is taken as
+// midnight UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, s)
+}
+
 func splitAndTrim(s string) []string {
 	parts := strings.Split(s, ",")
 	var result []string
@@ -137,3 +529,17 @@ func splitAndTrim(s string) []string {
 	}
 	return result
 }
+
+// splitPairs parses a comma-separated list of key=value pairs.
+func splitPairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, part := range splitAndTrim(s) {
+		key, value, ok := strings.Cut(part, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", part)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
@@ -20,22 +20,76 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, 2, cfg.NdotsValue)
 		assert.Equal(t, "change-ndots", cfg.AnnotationKey)
 		assert.Equal(t, "opt-out", cfg.AnnotationMode)
+		assert.Equal(t, "annotation", cfg.AnnotationSource)
 		assert.Len(t, cfg.NamespaceExclude, 3) // kube-system, kube-public, kube-node-lease
 		assert.Equal(t, 10*time.Second, cfg.Timeout)
 		// New fields
 		assert.Equal(t, "info", cfg.LogLevel)
 		assert.Equal(t, "json", cfg.LogFormat)
 		assert.Equal(t, 8080, cfg.MetricsPort)
+		assert.Equal(t, "cluster.local", cfg.ClusterDomain)
+		assert.Empty(t, cfg.FQDNRewriteNamespaces)
+		assert.Empty(t, cfg.SearchDomains)
+		assert.False(t, cfg.InheritFromOwner)
+		assert.Equal(t, 2, cfg.OwnerLookupDepth)
+		assert.Empty(t, cfg.OwnerResources)
+		assert.False(t, cfg.TenantPolicyEnabled)
+		assert.Equal(t, "ndots-policy", cfg.TenantPolicyConfigMap)
+		assert.False(t, cfg.EventsEnabled)
+		assert.False(t, cfg.PolicyReportEnabled)
+		assert.Equal(t, 10*time.Minute, cfg.PolicyReportResync)
+		assert.False(t, cfg.ComplianceMetricsEnabled)
+		assert.False(t, cfg.ExplainEnabled)
+		assert.Empty(t, cfg.DebugToken)
+		assert.Equal(t, 500, cfg.DecisionBufferSize)
+		assert.Empty(t, cfg.MutationCondition)
+		assert.Empty(t, cfg.NdotsExpression)
+		assert.Equal(t, uint64(1000000), cfg.ExpressionCostLimit)
+		assert.False(t, cfg.PatchTestOperations)
+		assert.Equal(t, "allow", cfg.FailureMode)
+		assert.Empty(t, cfg.FailureModeNamespaces)
+		assert.Equal(t, 0, cfg.DecisionCacheSize)
 	})
 
 	t.Run("from env", func(t *testing.T) {
 		require.NoError(t, os.Setenv("PORT", "9090"))
 		require.NoError(t, os.Setenv("NDOTS_VALUE", "5"))
+		require.NoError(t, os.Setenv("ANNOTATION_KEY", "ndots.example.com/change"))
+		require.NoError(t, os.Setenv("ANNOTATION_KEY_ALIASES", "change-ndots, legacy.example.com/ndots"))
 		require.NoError(t, os.Setenv("ANNOTATION_MODE", "opt-in"))
+		require.NoError(t, os.Setenv("ANNOTATION_SOURCE", "label"))
+		require.NoError(t, os.Setenv("INHERIT_FROM_OWNER", "true"))
+		require.NoError(t, os.Setenv("OWNER_LOOKUP_DEPTH", "3"))
+		require.NoError(t, os.Setenv("OWNER_RESOURCES", "apps/v1/ReplicaSet=replicasets, db.example.com/v1/Database=databases"))
+		require.NoError(t, os.Setenv("CACHE_SYNC_TIMEOUT", "5s"))
+		require.NoError(t, os.Setenv("TENANT_POLICY_ENABLED", "true"))
+		require.NoError(t, os.Setenv("TENANT_ALLOWED_MODES", "opt-in,opt-out"))
+		require.NoError(t, os.Setenv("TENANT_NDOTS_MAX", "3"))
+		require.NoError(t, os.Setenv("EVENTS_ENABLED", "true"))
+		require.NoError(t, os.Setenv("POLICY_REPORT_ENABLED", "true"))
+		require.NoError(t, os.Setenv("POLICY_REPORT_WRITE_QPS", "2"))
+		require.NoError(t, os.Setenv("POLICY_REPORT_RESYNC", "1m"))
+		require.NoError(t, os.Setenv("COMPLIANCE_METRICS_ENABLED", "true"))
+		require.NoError(t, os.Setenv("EXPLAIN_ENABLED", "true"))
+		require.NoError(t, os.Setenv("DEBUG_TOKEN", "s3cret"))
+		require.NoError(t, os.Setenv("DECISION_BUFFER_SIZE", "50"))
+		require.NoError(t, os.Setenv("MUTATION_CONDITION", `request.operation == "CREATE"`))
+		require.NoError(t, os.Setenv("NDOTS_EXPRESSION", "3"))
+		require.NoError(t, os.Setenv("EXPRESSION_COST_LIMIT", "5000"))
+		require.NoError(t, os.Setenv("PATCH_TEST_OPERATIONS", "true"))
+		require.NoError(t, os.Setenv("FAILURE_MODE", "deny"))
+		require.NoError(t, os.Setenv("FAILURE_MODE_NAMESPACES", "sandbox=allow, payments = deny"))
+		require.NoError(t, os.Setenv("DECISION_CACHE_SIZE", "1000"))
 		require.NoError(t, os.Setenv("NAMESPACE_INCLUDE", "prod,staging"))
 		require.NoError(t, os.Setenv("LOG_LEVEL", "debug"))
 		require.NoError(t, os.Setenv("LOG_FORMAT", "text"))
 		require.NoError(t, os.Setenv("METRICS_PORT", "9090"))
+		require.NoError(t, os.Setenv("METRICS_WORKLOAD_LABEL", "true"))
+		require.NoError(t, os.Setenv("CLUSTER_DOMAIN", "k8s.example.internal."))
+		require.NoError(t, os.Setenv("FQDN_REWRITE_NAMESPACES", "shop, payments"))
+		require.NoError(t, os.Setenv("SEARCH_DOMAINS", "corp.example.com, example.com"))
+		require.NoError(t, os.Setenv("ENFORCEMENT_AUDIT_UNTIL", "2026-11-01"))
+		require.NoError(t, os.Setenv("ENFORCEMENT_WARN_UNTIL", "2026-12-01T09:00:00+01:00"))
 
 		defer os.Clearenv()
 
@@ -43,11 +97,60 @@ func TestLoad(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, 9090, cfg.Port)
 		assert.Equal(t, 5, cfg.NdotsValue)
+		assert.Equal(t, "ndots.example.com/change", cfg.AnnotationKey)
+		assert.Equal(t, []string{"change-ndots", "legacy.example.com/ndots"}, cfg.AnnotationKeyAliases)
 		assert.Equal(t, "opt-in", cfg.AnnotationMode)
+		assert.Equal(t, "label", cfg.AnnotationSource)
+		assert.True(t, cfg.InheritFromOwner)
+		assert.Equal(t, 3, cfg.OwnerLookupDepth)
+		assert.Equal(t, []string{"apps/v1/ReplicaSet=replicasets", "db.example.com/v1/Database=databases"}, cfg.OwnerResources)
+		assert.Equal(t, 5*time.Second, cfg.CacheSyncTimeout)
+		assert.True(t, cfg.TenantPolicyEnabled)
+		assert.Equal(t, []string{"opt-in", "opt-out"}, cfg.TenantAllowedModes)
+		assert.Equal(t, 1, cfg.TenantNdotsMin)
+		assert.Equal(t, 3, cfg.TenantNdotsMax)
+		assert.True(t, cfg.EventsEnabled)
+		assert.True(t, cfg.PolicyReportEnabled)
+		assert.Equal(t, 2, cfg.PolicyReportWriteQPS)
+		assert.Equal(t, time.Minute, cfg.PolicyReportResync)
+		assert.True(t, cfg.ComplianceMetricsEnabled)
+		assert.True(t, cfg.ExplainEnabled)
+		assert.Equal(t, "s3cret", cfg.DebugToken)
+		assert.Equal(t, 50, cfg.DecisionBufferSize)
+		assert.Equal(t, `request.operation == "CREATE"`, cfg.MutationCondition)
+		assert.Equal(t, "3", cfg.NdotsExpression)
+		assert.Equal(t, uint64(5000), cfg.ExpressionCostLimit)
+		assert.True(t, cfg.PatchTestOperations)
+		assert.Equal(t, "deny", cfg.FailureMode)
+		assert.Equal(t, map[string]string{"sandbox": "allow", "payments": "deny"}, cfg.FailureModeNamespaces)
+		assert.Equal(t, 1000, cfg.DecisionCacheSize)
 		assert.Equal(t, []string{"prod", "staging"}, cfg.NamespaceInclude)
 		assert.Equal(t, "debug", cfg.LogLevel)
 		assert.Equal(t, "text", cfg.LogFormat)
 		assert.Equal(t, 9090, cfg.MetricsPort)
+		assert.True(t, cfg.MetricsWorkloadLabel)
+		assert.Equal(t, "k8s.example.internal", cfg.ClusterDomain)
+		assert.Equal(t, []string{"shop", "payments"}, cfg.FQDNRewriteNamespaces)
+		assert.Equal(t, []string{"corp.example.com", "example.com"}, cfg.SearchDomains)
+		assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), cfg.AuditUntil)
+		assert.True(t, time.Date(2026, 12, 1, 8, 0, 0, 0, time.UTC).Equal(cfg.WarnUntil))
+	})
+
+	t.Run("invalid enforcement date", func(t *testing.T) {
+		require.NoError(t, os.Setenv("ENFORCEMENT_WARN_UNTIL", "next tuesday"))
+		defer os.Clearenv()
+
+		_, err := Load()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ENFORCEMENT_WARN_UNTIL")
+	})
+
+	t.Run("invalid failure mode namespaces", func(t *testing.T) {
+		require.NoError(t, os.Setenv("FAILURE_MODE_NAMESPACES", "payments"))
+		defer os.Clearenv()
+
+		_, err := Load()
+		assert.EqualError(t, err, `invalid FAILURE_MODE_NAMESPACES: "payments" is not a key=value pair`)
 	})
 
 	t.Run("bad env", func(t *testing.T) {
@@ -63,6 +166,41 @@ func TestLoad(t *testing.T) {
 	})
 }
 
+func TestLoadCandidate(t *testing.T) {
+	os.Clearenv()
+	active := DefaultConfig
+
+	t.Run("no candidate", func(t *testing.T) {
+		cfg, err := LoadCandidate(&active)
+		require.NoError(t, err)
+		assert.Nil(t, cfg)
+	})
+
+	t.Run("candidate overrides policy", func(t *testing.T) {
+		require.NoError(t, os.Setenv("CANDIDATE_NDOTS_VALUE", "1"))
+		require.NoError(t, os.Setenv("CANDIDATE_ANNOTATION_MODE", "always"))
+		require.NoError(t, os.Setenv("NDOTS_VALUE", "3"))
+		defer os.Clearenv()
+
+		cfg, err := LoadCandidate(&active)
+		require.NoError(t, err)
+		require.NotNil(t, cfg)
+		assert.Equal(t, 1, cfg.NdotsValue)
+		assert.Equal(t, "always", cfg.AnnotationMode)
+		assert.Equal(t, active.NamespaceExclude, cfg.NamespaceExclude)
+		assert.Equal(t, 2, active.NdotsValue)
+	})
+
+	t.Run("invalid candidate", func(t *testing.T) {
+		require.NoError(t, os.Setenv("CANDIDATE_ANNOTATION_MODE", "sometimes"))
+		defer os.Clearenv()
+
+		_, err := LoadCandidate(&active)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "candidate")
+	})
+}
+
 func TestConfig_Validate(t *testing.T) {
 	t.Run("valid", func(t *testing.T) {
 		cfg := DefaultConfig
@@ -78,6 +216,25 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Contains(t, err.Error(), "port")
 	})
 
+	t.Run("invalid failure mode", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.FailureMode = "fail"
+		err := cfg.Validate()
+		assert.EqualError(t, err, "failureMode must be 'allow' or 'deny'")
+
+		cfg = DefaultConfig
+		cfg.FailureModeNamespaces = map[string]string{"payments": "closed"}
+		err = cfg.Validate()
+		assert.EqualError(t, err, `failureModeNamespaces has invalid mode "closed" for namespace "payments"`)
+	})
+
+	t.Run("negative decision cache size", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.DecisionCacheSize = -1
+		err := cfg.Validate()
+		assert.EqualError(t, err, "decisionCacheSize must not be negative")
+	})
+
 	t.Run("invalid ndots", func(t *testing.T) {
 		cfg := DefaultConfig
 		cfg.NdotsValue = 16
@@ -86,6 +243,143 @@ func TestConfig_Validate(t *testing.T) {
 		assert.Contains(t, err.Error(), "ndots")
 	})
 
+	t.Run("invalid annotation key", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.AnnotationKey = "ndots.example.com/change/now"
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "annotationKey")
+	})
+
+	t.Run("invalid annotation key alias", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.AnnotationKeyAliases = []string{"change ndots"}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "annotationKeyAliases")
+	})
+
+	t.Run("annotation key alias repeats key", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.AnnotationKeyAliases = []string{"legacy-ndots", cfg.AnnotationKey}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "annotationKeyAliases")
+	})
+
+	t.Run("fqdn rewrite without cluster domain", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.ClusterDomain = ""
+		cfg.FQDNRewriteNamespaces = []string{"shop"}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "clusterDomain")
+	})
+
+	t.Run("invalid search domain", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.SearchDomains = []string{"corp.example.com", "Corp_Example"}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "searchDomains")
+	})
+
+	t.Run("policy report without writes", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.PolicyReportEnabled = true
+		cfg.PolicyReportWriteQPS = 0
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "policyReportWriteQPS")
+	})
+
+	t.Run("debug endpoints without decision buffer", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.DebugToken = "s3cret"
+		cfg.DecisionBufferSize = 0
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "decisionBufferSize")
+	})
+
+	t.Run("mutation condition does not compile", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.MutationCondition = "object.metadata.labels["
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mutationCondition")
+	})
+
+	t.Run("ndots expression has wrong type", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.NdotsExpression = `"2"`
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ndotsExpression is invalid: must evaluate to int, not string")
+	})
+
+	t.Run("expressions without cost limit", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.NdotsExpression = "2"
+		cfg.ExpressionCostLimit = 0
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "expressionCostLimit")
+	})
+
+	t.Run("warn phase ends before audit phase", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.AuditUntil = time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+		cfg.WarnUntil = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "warnUntil")
+	})
+
+	t.Run("owner lookup depth out of range", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.InheritFromOwner = true
+		cfg.OwnerLookupDepth = 10
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ownerLookupDepth")
+	})
+
+	t.Run("malformed owner resource", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.OwnerResources = []string{"db.example.com/v1/Database"}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "ownerResources")
+	})
+
+	t.Run("invalid tenant limits", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.TenantPolicyEnabled = true
+		cfg.TenantNdotsMin = 4
+		cfg.TenantNdotsMax = 2
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "tenantNdotsMin")
+	})
+
+	t.Run("invalid tenant mode", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.TenantPolicyEnabled = true
+		cfg.TenantAllowedModes = []string{"opt-in", "never"}
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "never")
+	})
+
+	t.Run("invalid annotation source", func(t *testing.T) {
+		cfg := DefaultConfig
+		cfg.AnnotationSource = "header"
+		err := cfg.Validate()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "annotationSource")
+	})
+
 	t.Run("invalid annot mode", func(t *testing.T) {
 		cfg := DefaultConfig
 		cfg.AnnotationMode = "foo"
@@ -0,0 +1,94 @@
+// Package events records Kubernetes Events about admitted pods without
+// adding latency to admission requests.
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type event struct {
+	ref       *corev1.ObjectReference
+	eventType string
+	reason    string
+	message   string
+}
+
+// Recorder queues pod events and hands them to a client-go EventRecorder,
+// whose broadcaster deduplicates and rate limits them, from a background
+// goroutine. It implements admission.PodEventRecorder.
+type Recorder struct {
+	recorder record.EventRecorder
+	queue    chan event
+	logger   *slog.Logger
+}
+
+// NewRecorder creates a Recorder that buffers up to queueSize events. Events
+// are only delivered while Run is running.
+func NewRecorder(recorder record.EventRecorder, queueSize int, logger *slog.Logger) *Recorder {
+	return &Recorder{
+		recorder: recorder,
+		queue:    make(chan event, queueSize),
+		logger:   logger,
+	}
+}
+
+// Run delivers queued events until ctx is done.
+func (r *Recorder) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-r.queue:
+			r.recorder.Event(e.ref, e.eventType, e.reason, e.message)
+		}
+	}
+}
+
+// PodEvent queues an event about pod, admitted in namespace. It never
+// blocks: the event is dropped if the queue is full or the pod has no
+// controlling owner to attach it to.
+func (r *Recorder) PodEvent(pod *corev1.Pod, namespace, eventType, reason, message string) {
+	ref := Reference(pod, namespace)
+	if ref == nil {
+		r.logger.Debug("pod has no controlling owner, dropping event",
+			"namespace", namespace,
+			"name", pod.Name,
+			"reason", reason,
+		)
+		return
+	}
+
+	select {
+	case r.queue <- event{ref: ref, eventType: eventType, reason: reason, message: message}:
+	default:
+		r.logger.Warn("event queue full, dropping event",
+			"namespace", namespace,
+			"name", ref.Name,
+			"reason", reason,
+		)
+	}
+}
+
+// Reference returns the object an event about pod is attached to: its
+// controlling owner, such as a ReplicaSet or Job. The pod itself cannot be
+// referenced: at admission it has no UID yet, which kubectl describe filters
+// events on, and it may still be rejected after this webhook. It returns nil
+// for pods without a controlling owner.
+func Reference(pod *corev1.Pod, namespace string) *corev1.ObjectReference {
+	owner := metav1.GetControllerOfNoCopy(pod)
+	if owner == nil {
+		return nil
+	}
+	return &corev1.ObjectReference{
+		APIVersion: owner.APIVersion,
+		Kind:       owner.Kind,
+		Namespace:  namespace,
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+}
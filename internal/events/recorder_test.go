@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func ownedPod() *corev1.Pod {
+	controller := true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "web-7d9f8-",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       "web-7d9f8",
+				UID:        "uid-rs",
+				Controller: &controller,
+			}},
+		},
+	}
+}
+
+func TestReference(t *testing.T) {
+	t.Run("pod of a CREATE request", func(t *testing.T) {
+		// The pod as the API server sends it to mutating webhooks: no name
+		// or UID yet.
+		body := []byte(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{
+			"uid":"req-1","operation":"CREATE","namespace":"shop",
+			"object":{"apiVersion":"v1","kind":"Pod","metadata":{"generateName":"web-7d9f8-","namespace":"shop",
+				"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"web-7d9f8","uid":"uid-rs","controller":true,"blockOwnerDeletion":true}]},
+				"spec":{"containers":[{"name":"app","image":"web"}]}}}}`)
+		var review admissionv1.AdmissionReview
+		require.NoError(t, json.Unmarshal(body, &review))
+		var pod corev1.Pod
+		require.NoError(t, json.Unmarshal(review.Request.Object.Raw, &pod))
+
+		assert.Equal(t, &corev1.ObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "ReplicaSet",
+			Namespace:  "shop",
+			Name:       "web-7d9f8",
+			UID:        "uid-rs",
+		}, Reference(&pod, review.Request.Namespace))
+	})
+
+	t.Run("named pod with owner", func(t *testing.T) {
+		pod := ownedPod()
+		pod.Name = "web-7d9f8-abcde"
+		ref := Reference(pod, "shop")
+		require.NotNil(t, ref)
+		assert.Equal(t, "ReplicaSet", ref.Kind)
+		assert.Equal(t, "web-7d9f8", ref.Name)
+	})
+
+	t.Run("no owner", func(t *testing.T) {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+		assert.Nil(t, Reference(pod, "shop"))
+	})
+}
+
+func TestRecorder_PodEvent(t *testing.T) {
+	client := fake.NewClientset()
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	defer broadcaster.Shutdown()
+
+	r := NewRecorder(broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "test"}), 10, slog.Default())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	r.PodEvent(ownedPod(), "shop", corev1.EventTypeNormal, "NdotsMutated", "set ndots to 2")
+
+	var events *corev1.EventList
+	require.Eventually(t, func() bool {
+		var err error
+		events, err = client.CoreV1().Events("shop").List(ctx, metav1.ListOptions{})
+		return err == nil && len(events.Items) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	e := events.Items[0]
+	assert.Equal(t, "ReplicaSet", e.InvolvedObject.Kind)
+	assert.Equal(t, "web-7d9f8", e.InvolvedObject.Name)
+	assert.Equal(t, "NdotsMutated", e.Reason)
+	assert.Equal(t, "set ndots to 2", e.Message)
+}
+
+func TestRecorder_PodEventDoesNotBlock(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(10)
+	r := NewRecorder(fakeRecorder, 1, slog.Default())
+	pod := ownedPod()
+
+	// Run is not started, so the second event finds the queue full.
+	r.PodEvent(pod, "shop", corev1.EventTypeNormal, "NdotsMutated", "first")
+	r.PodEvent(pod, "shop", corev1.EventTypeNormal, "NdotsMutated", "second")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	assert.Equal(t, "Normal NdotsMutated first", <-fakeRecorder.Events)
+	assert.Never(t, func() bool { return len(fakeRecorder.Events) > 0 }, 50*time.Millisecond, 10*time.Millisecond)
+}
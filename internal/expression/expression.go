@@ -0,0 +1,148 @@
+// Package expression compiles and evaluates the CEL expressions of the
+// mutation policy.
+package expression
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+)
+
+// Variables available to expressions, named like those of Kubernetes
+// admission policies. Each is the object in its JSON form.
+const (
+	// VarObject is the admitted pod.
+	VarObject = "object"
+	// VarNamespaceObject is the pod's namespace, or null if it is not known.
+	VarNamespaceObject = "namespaceObject"
+	// VarRequest is the admission request: uid, operation, namespace,
+	// userInfo and dryRun.
+	VarRequest = "request"
+)
+
+// DefaultCostLimit is the runtime cost limit of one evaluation, the same as
+// the per-expression limit of Kubernetes admission policies.
+const DefaultCostLimit = 1000000
+
+// Type is the type an expression must evaluate to.
+type Type int
+
+const (
+	Bool Type = iota
+	Int
+)
+
+func (t Type) String() string {
+	if t == Int {
+		return "int"
+	}
+	return "bool"
+}
+
+func (t Type) cel() *cel.Type {
+	if t == Int {
+		return cel.IntType
+	}
+	return cel.BoolType
+}
+
+// Vars are the values of the variables for one evaluation.
+type Vars struct {
+	Object          map[string]interface{}
+	NamespaceObject map[string]interface{}
+	Request         map[string]interface{}
+}
+
+func (v Vars) activation() map[string]interface{} {
+	var namespace interface{}
+	if v.NamespaceObject != nil {
+		namespace = v.NamespaceObject
+	}
+	return map[string]interface{}{
+		VarObject:          v.Object,
+		VarNamespaceObject: namespace,
+		VarRequest:         v.Request,
+	}
+}
+
+// Expression is a compiled and type-checked expression. It is safe for
+// concurrent use.
+type Expression struct {
+	source  string
+	program cel.Program
+}
+
+var environment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable(VarObject, cel.DynType),
+		cel.Variable(VarNamespaceObject, cel.DynType),
+		cel.Variable(VarRequest, cel.DynType),
+		ext.Strings(),
+	)
+})
+
+// Compile compiles source and checks that it evaluates to typ. Evaluations
+// fail once they exceed costLimit.
+func Compile(source string, typ Type, costLimit uint64) (*Expression, error) {
+	env, err := environment()
+	if err != nil {
+		return nil, fmt.Errorf("create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(source)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if out := ast.OutputType(); !out.IsExactType(typ.cel()) && !out.IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("must evaluate to %s, not %s", typ, out)
+	}
+	program, err := env.Program(ast, cel.CostLimit(costLimit), cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{source: source, program: program}, nil
+}
+
+// Source returns the expression as written.
+func (e *Expression) Source() string {
+	return e.source
+}
+
+// EvalBool evaluates a Bool expression.
+func (e *Expression) EvalBool(ctx context.Context, vars Vars) (bool, error) {
+	out, err := e.eval(ctx, vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := out.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %T, want bool", out)
+	}
+	return b, nil
+}
+
+// EvalInt evaluates an Int expression.
+func (e *Expression) EvalInt(ctx context.Context, vars Vars) (int64, error) {
+	out, err := e.eval(ctx, vars)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := out.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expression returned %T, want int", out)
+	}
+	return n, nil
+}
+
+func (e *Expression) eval(ctx context.Context, vars Vars) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	out, _, err := e.program.ContextEval(ctx, vars.activation())
+	if err != nil {
+		return nil, err
+	}
+	return out.Value(), nil
+}
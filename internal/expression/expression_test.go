@@ -0,0 +1,98 @@
+package expression
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		typ     Type
+		wantErr string
+	}{
+		{name: "bool", source: `object.metadata.name.startsWith("web")`, typ: Bool},
+		{name: "int", source: "2", typ: Int},
+		{name: "dynamic result", source: `int(object.metadata.annotations["ndots"])`, typ: Int},
+		{name: "syntax error", source: "object.metadata.", typ: Bool, wantErr: "Syntax error"},
+		{name: "unknown variable", source: "pod.metadata.name == 'x'", typ: Bool, wantErr: "undeclared reference to 'pod'"},
+		{name: "wrong type", source: "1 + 1", typ: Bool, wantErr: "must evaluate to bool, not int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Compile(tt.source, tt.typ, DefaultCostLimit)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.source, expr.Source())
+		})
+	}
+}
+
+func TestExpression_Eval(t *testing.T) {
+	vars := Vars{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":   "web-1",
+				"labels": map[string]interface{}{"tier": "frontend"},
+			},
+		},
+		Request: map[string]interface{}{"operation": "CREATE", "dryRun": false},
+	}
+
+	t.Run("bool", func(t *testing.T) {
+		expr, err := Compile(`object.metadata.labels.tier == "frontend" && request.operation == "CREATE"`, Bool, DefaultCostLimit)
+		require.NoError(t, err)
+		ok, err := expr.EvalBool(context.Background(), vars)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("int", func(t *testing.T) {
+		expr, err := Compile(`object.metadata.labels.tier == "frontend" ? 1 : 3`, Int, DefaultCostLimit)
+		require.NoError(t, err)
+		n, err := expr.EvalInt(context.Background(), vars)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
+	t.Run("namespace unknown", func(t *testing.T) {
+		expr, err := Compile(`namespaceObject == null`, Bool, DefaultCostLimit)
+		require.NoError(t, err)
+		ok, err := expr.EvalBool(context.Background(), vars)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("dynamic result of wrong type", func(t *testing.T) {
+		expr, err := Compile(`object.metadata.name`, Int, DefaultCostLimit)
+		require.NoError(t, err)
+		_, err = expr.EvalInt(context.Background(), vars)
+		assert.EqualError(t, err, "expression returned string, want int")
+	})
+
+	t.Run("cost limit", func(t *testing.T) {
+		expr, err := Compile(`[1, 2, 3, 4, 5].all(x, [1, 2, 3, 4, 5].all(y, x + y > 0))`, Bool, 10)
+		require.NoError(t, err)
+		_, err = expr.EvalBool(context.Background(), vars)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cost limit exceeded")
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		expr, err := Compile(`[1, 2, 3].all(x, [1, 2, 3].all(y, x + y > 0))`, Bool, DefaultCostLimit)
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = expr.EvalBool(ctx, vars)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+)
+
+// PodChecker classifies pods against the ndots policy. It is implemented by
+// admission.Mutator.
+type PodChecker interface {
+	Check(pod *corev1.Pod) admission.ComplianceResult
+}
+
+// ComplianceCollector exposes the compliance of running pods, evaluated from
+// a pod informer cache at scrape time. It exports nothing until the cache
+// has synced.
+type ComplianceCollector struct {
+	pods    corelisters.PodLister
+	synced  cache.InformerSynced
+	checker PodChecker
+
+	podsByState *prometheus.Desc
+	podsByNdots *prometheus.Desc
+}
+
+// NewComplianceCollector creates a collector over the pods in lister.
+func NewComplianceCollector(lister corelisters.PodLister, synced cache.InformerSynced, checker PodChecker) *ComplianceCollector {
+	return &ComplianceCollector{
+		pods:    lister,
+		synced:  synced,
+		checker: checker,
+		podsByState: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pods"),
+			"Number of running pods by compliance with the ndots policy (compliant, non-compliant, exempt, skipped)",
+			[]string{"namespace", "state"}, nil,
+		),
+		podsByNdots: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "pods_by_ndots"),
+			"Number of running pods by their effective ndots value",
+			[]string{"namespace", "ndots"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ComplianceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.podsByState
+	ch <- c.podsByNdots
+}
+
+// Collect implements prometheus.Collector.
+func (c *ComplianceCollector) Collect(ch chan<- prometheus.Metric) {
+	if !c.synced() {
+		return
+	}
+	pods, err := c.pods.List(labels.Everything())
+	if err != nil {
+		return
+	}
+
+	type key struct{ namespace, value string }
+	byState := make(map[key]int)
+	byNdots := make(map[key]int)
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		result := c.checker.Check(pod)
+		byState[key{pod.Namespace, string(result.Compliance)}]++
+		byNdots[key{pod.Namespace, strconv.Itoa(result.Ndots)}]++
+	}
+
+	for k, n := range byState {
+		ch <- prometheus.MustNewConstMetric(c.podsByState, prometheus.GaugeValue, float64(n), k.namespace, k.value)
+	}
+	for k, n := range byNdots {
+		ch <- prometheus.MustNewConstMetric(c.podsByNdots, prometheus.GaugeValue, float64(n), k.namespace, k.value)
+	}
+}
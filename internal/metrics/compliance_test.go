@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func TestComplianceCollector(t *testing.T) {
+	two := "2"
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pod := range []*corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}, Spec: corev1.PodSpec{
+			DNSConfig: &corev1.PodDNSConfig{Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &two}}},
+		}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "legacy", Annotations: map[string]string{"change-ndots": "false"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "done"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}},
+	} {
+		require.NoError(t, indexer.Add(pod))
+	}
+
+	mutator := admission.NewMutator(&config.Config{
+		NdotsValue:       2,
+		AnnotationKey:    "change-ndots",
+		AnnotationMode:   "opt-out",
+		NamespaceExclude: []string{"kube-system"},
+	}, slog.Default())
+	collector := NewComplianceCollector(corelisters.NewPodLister(indexer), func() bool { return true }, mutator)
+
+	expected := `
+# HELP ndots_webhook_pods Number of running pods by compliance with the ndots policy (compliant, non-compliant, exempt, skipped)
+# TYPE ndots_webhook_pods gauge
+ndots_webhook_pods{namespace="kube-system",state="exempt"} 1
+ndots_webhook_pods{namespace="shop",state="compliant"} 1
+ndots_webhook_pods{namespace="shop",state="non-compliant"} 1
+ndots_webhook_pods{namespace="shop",state="skipped"} 1
+# HELP ndots_webhook_pods_by_ndots Number of running pods by their effective ndots value
+# TYPE ndots_webhook_pods_by_ndots gauge
+ndots_webhook_pods_by_ndots{namespace="kube-system",ndots="5"} 1
+ndots_webhook_pods_by_ndots{namespace="shop",ndots="2"} 1
+ndots_webhook_pods_by_ndots{namespace="shop",ndots="5"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
+
+func TestComplianceCollector_NotSynced(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}}))
+
+	mutator := admission.NewMutator(&config.Config{NdotsValue: 2}, slog.Default())
+	collector := NewComplianceCollector(corelisters.NewPodLister(indexer), func() bool { return false }, mutator)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(collector))
+}
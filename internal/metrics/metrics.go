@@ -3,6 +3,8 @@ package metrics
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
 )
 
 const (
@@ -11,21 +13,54 @@ const (
 
 // Recorder records webhook metrics to Prometheus.
 type Recorder struct {
-	mutationsTotal  *prometheus.CounterVec
-	errorsTotal     *prometheus.CounterVec
-	requestDuration prometheus.Histogram
+	mutationsTotal   *prometheus.CounterVec
+	errorsTotal      *prometheus.CounterVec
+	failuresTotal    *prometheus.CounterVec
+	requestDuration  prometheus.Histogram
+	hostnameWarnings *prometheus.CounterVec
+	shadowResults    *prometheus.CounterVec
+	deprecatedKeys   *prometheus.CounterVec
+	expressionEvals  *prometheus.CounterVec
+	expressionTime   *prometheus.HistogramVec
+	decisionCache    *prometheus.CounterVec
+	workloadLabel    bool
+}
+
+type options struct {
+	workloadLabel bool
+}
+
+// Option configures a Recorder.
+type Option func(*options)
+
+// WithWorkloadLabel adds workload_kind and workload_name labels to
+// mutations_total. Its cardinality then grows with the number of workloads.
+func WithWorkloadLabel() Option {
+	return func(o *options) {
+		o.workloadLabel = true
+	}
 }
 
 // NewRecorder creates a new metrics Recorder and registers metrics with the given registry.
-func NewRecorder(reg prometheus.Registerer) *Recorder {
+func NewRecorder(reg prometheus.Registerer, opts ...Option) *Recorder {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	mutationLabels := []string{"namespace", "action", "reason"}
+	if o.workloadLabel {
+		mutationLabels = []string{"namespace", "workload_kind", "workload_name", "action", "reason"}
+	}
+
 	r := &Recorder{
+		workloadLabel: o.workloadLabel,
 		mutationsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: namespace,
 				Name:      "mutations_total",
 				Help:      "Total number of pod mutations processed",
 			},
-			[]string{"namespace", "action"},
+			mutationLabels,
 		),
 		errorsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -35,6 +70,14 @@ func NewRecorder(reg prometheus.Registerer) *Recorder {
 			},
 			[]string{"type"},
 		),
+		failuresTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "failures_total",
+				Help:      "Total number of pods admitted or denied because of an error, by error type",
+			},
+			[]string{"type", "action"},
+		),
 		requestDuration: prometheus.NewHistogram(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
@@ -43,28 +86,126 @@ func NewRecorder(reg prometheus.Registerer) *Recorder {
 				Buckets:   prometheus.DefBuckets,
 			},
 		),
+		hostnameWarnings: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "hostname_warnings_total",
+				Help:      "Total number of hostnames in admitted pods that resolve slower with the configured ndots",
+			},
+			[]string{"namespace"},
+		),
+		shadowResults: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "shadow_evaluations_total",
+				Help:      "Total number of candidate policy evaluations by result",
+			},
+			[]string{"namespace", "result"},
+		),
+		deprecatedKeys: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "deprecated_key_total",
+				Help:      "Total number of admitted pods that use a deprecated alias of the opt-in/opt-out key",
+			},
+			[]string{"namespace", "key"},
+		),
+		expressionEvals: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "expression_evaluations_total",
+				Help:      "Total number of policy expression evaluations by result",
+			},
+			[]string{"expression", "result"},
+		),
+		expressionTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "expression_evaluation_duration_seconds",
+				Help:      "Duration of policy expression evaluations in seconds",
+				Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05},
+			},
+			[]string{"expression"},
+		),
+		decisionCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "decision_cache_requests_total",
+				Help:      "Total number of decision cache lookups by result",
+			},
+			[]string{"result"},
+		),
 	}
 
 	reg.MustRegister(r.mutationsTotal)
 	reg.MustRegister(r.errorsTotal)
+	reg.MustRegister(r.failuresTotal)
 	reg.MustRegister(r.requestDuration)
+	reg.MustRegister(r.hostnameWarnings)
+	reg.MustRegister(r.shadowResults)
+	reg.MustRegister(r.deprecatedKeys)
+	reg.MustRegister(r.expressionEvals)
+	reg.MustRegister(r.expressionTime)
+	reg.MustRegister(r.decisionCache)
 
 	return r
 }
 
 // RecordMutation records a mutation event.
-// action should be "mutated" or "skipped".
-func (r *Recorder) RecordMutation(namespace, action string) {
-	r.mutationsTotal.WithLabelValues(namespace, action).Inc()
+// action should be "mutated", "skipped", or the enforcement phase ("audit",
+// "warn") for mutations deferred by the schedule; reason is the decision's
+// reason code. workload is only recorded with WithWorkloadLabel.
+func (r *Recorder) RecordMutation(namespace string, workload admission.Workload, action string, reason admission.ReasonCode) {
+	if r.workloadLabel {
+		r.mutationsTotal.WithLabelValues(namespace, workload.Kind, workload.Name, action, string(reason)).Inc()
+		return
+	}
+	r.mutationsTotal.WithLabelValues(namespace, action, string(reason)).Inc()
 }
 
-// RecordError records an error event.
-// errorType should be "decode", "mutation", or "marshal".
-func (r *Recorder) RecordError(errorType string) {
-	r.errorsTotal.WithLabelValues(errorType).Inc()
+// RecordError records an error event, labelled with its code.
+func (r *Recorder) RecordError(code admission.ErrorCode) {
+	r.errorsTotal.WithLabelValues(string(code)).Inc()
+}
+
+// RecordFailure records the answer to a request that failed with an error of
+// code. action should be "allowed" or "denied", as set by the failure mode.
+func (r *Recorder) RecordFailure(code admission.ErrorCode, action string) {
+	r.failuresTotal.WithLabelValues(string(code), action).Inc()
 }
 
 // ObserveRequestDuration records the duration of a request.
 func (r *Recorder) ObserveRequestDuration(seconds float64) {
 	r.requestDuration.Observe(seconds)
 }
+
+// RecordHostnameWarnings records hostnames that resolve slower after mutation.
+func (r *Recorder) RecordHostnameWarnings(namespace string, count int) {
+	r.hostnameWarnings.WithLabelValues(namespace).Add(float64(count))
+}
+
+// RecordShadowResult records the outcome of a candidate policy evaluation.
+// result should be "match", "would-mutate", "would-skip", "different-patch",
+// or "error".
+func (r *Recorder) RecordShadowResult(namespace, result string) {
+	r.shadowResults.WithLabelValues(namespace, result).Inc()
+}
+
+// RecordDeprecatedKey records a pod that uses the deprecated key alias key.
+func (r *Recorder) RecordDeprecatedKey(namespace, key string) {
+	r.deprecatedKeys.WithLabelValues(namespace, key).Inc()
+}
+
+// RecordExpression records the evaluation of the policy expression name.
+// result should be "true" or "false" for the condition, "ok" for the ndots
+// expression, or "error".
+func (r *Recorder) RecordExpression(name, result string, seconds float64) {
+	r.expressionEvals.WithLabelValues(name, result).Inc()
+	r.expressionTime.WithLabelValues(name).Observe(seconds)
+}
+
+// RecordDecisionCache records a decision cache lookup. result should be
+// "hit", "miss", or "bypass" for pods whose decision cannot be cached.
+func (r *Recorder) RecordDecisionCache(result string) {
+	r.decisionCache.WithLabelValues(result).Inc()
+}
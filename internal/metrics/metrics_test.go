@@ -7,6 +7,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
 )
 
 func TestNewRecorder(t *testing.T) {
@@ -16,21 +18,26 @@ func TestNewRecorder(t *testing.T) {
 	require.NotNil(t, recorder)
 }
 
+var web = admission.Workload{Kind: "Deployment", Name: "web"}
+
 func TestRecorder_RecordMutation(t *testing.T) {
 	tests := []struct {
 		name      string
 		namespace string
 		action    string
+		reason    admission.ReasonCode
 	}{
 		{
 			name:      "mutated action",
 			namespace: "default",
 			action:    "mutated",
+			reason:    admission.ReasonNotOptedOut,
 		},
 		{
 			name:      "skipped action",
 			namespace: "kube-system",
 			action:    "skipped",
+			reason:    admission.ReasonNamespaceExcluded,
 		},
 	}
 
@@ -39,9 +46,9 @@ func TestRecorder_RecordMutation(t *testing.T) {
 			reg := prometheus.NewRegistry()
 			recorder := NewRecorder(reg)
 
-			recorder.RecordMutation(tt.namespace, tt.action)
+			recorder.RecordMutation(tt.namespace, web, tt.action, tt.reason)
 
-			count := testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues(tt.namespace, tt.action))
+			count := testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues(tt.namespace, tt.action, string(tt.reason)))
 			assert.Equal(t, float64(1), count)
 		})
 	}
@@ -50,11 +57,12 @@ func TestRecorder_RecordMutation(t *testing.T) {
 func TestRecorder_RecordError(t *testing.T) {
 	tests := []struct {
 		name      string
-		errorType string
+		errorType admission.ErrorCode
 	}{
-		{"decode error", "decode"},
-		{"mutation error", "mutation"},
-		{"marshal error", "marshal"},
+		{"decode error", admission.CodeDecodeReview},
+		{"policy error", admission.CodePolicy},
+		{"marshal error", admission.CodeMarshal},
+		{"verify error", admission.CodePatchVerify},
 	}
 
 	for _, tt := range tests {
@@ -64,12 +72,24 @@ func TestRecorder_RecordError(t *testing.T) {
 
 			recorder.RecordError(tt.errorType)
 
-			count := testutil.ToFloat64(recorder.errorsTotal.WithLabelValues(tt.errorType))
+			count := testutil.ToFloat64(recorder.errorsTotal.WithLabelValues(string(tt.errorType)))
 			assert.Equal(t, float64(1), count)
 		})
 	}
 }
 
+func TestRecorder_RecordFailure(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordFailure(admission.CodePolicy, "allowed")
+	recorder.RecordFailure(admission.CodePatchVerify, "denied")
+	recorder.RecordFailure(admission.CodePatchVerify, "denied")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.failuresTotal.WithLabelValues("policy", "allowed")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(recorder.failuresTotal.WithLabelValues("patch-verify", "denied")))
+}
+
 func TestRecorder_ObserveRequestDuration(t *testing.T) {
 	reg := prometheus.NewRegistry()
 	recorder := NewRecorder(reg)
@@ -81,18 +101,84 @@ func TestRecorder_ObserveRequestDuration(t *testing.T) {
 	assert.Equal(t, 1, count)
 }
 
+func TestRecorder_RecordHostnameWarnings(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordHostnameWarnings("default", 2)
+	recorder.RecordHostnameWarnings("default", 1)
+
+	count := testutil.ToFloat64(recorder.hostnameWarnings.WithLabelValues("default"))
+	assert.Equal(t, float64(3), count)
+}
+
+func TestRecorder_RecordShadowResult(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordShadowResult("default", "would-mutate")
+
+	count := testutil.ToFloat64(recorder.shadowResults.WithLabelValues("default", "would-mutate"))
+	assert.Equal(t, float64(1), count)
+}
+
+func TestRecorder_RecordDeprecatedKey(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordDeprecatedKey("default", "change-ndots")
+
+	count := testutil.ToFloat64(recorder.deprecatedKeys.WithLabelValues("default", "change-ndots"))
+	assert.Equal(t, float64(1), count)
+}
+
+func TestRecorder_RecordExpression(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordExpression(admission.ExpressionCondition, "true", 0.0001)
+	recorder.RecordExpression(admission.ExpressionCondition, "error", 0.0002)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.expressionEvals.WithLabelValues("condition", "true")))
+	assert.Equal(t, 1, testutil.CollectAndCount(recorder.expressionTime))
+}
+
+func TestRecorder_RecordMutation_WorkloadLabel(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg, WithWorkloadLabel())
+
+	recorder.RecordMutation("default", web, "mutated", admission.ReasonAlways)
+
+	count := testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "Deployment", "web", "mutated", "always"))
+	assert.Equal(t, float64(1), count)
+}
+
 func TestRecorder_MultipleRecordings(t *testing.T) {
 	reg := prometheus.NewRegistry()
 	recorder := NewRecorder(reg)
 
 	// Record multiple mutations
-	recorder.RecordMutation("default", "mutated")
-	recorder.RecordMutation("default", "mutated")
-	recorder.RecordMutation("prod", "mutated")
-	recorder.RecordMutation("default", "skipped")
+	recorder.RecordMutation("default", web, "mutated", admission.ReasonAlways)
+	recorder.RecordMutation("default", web, "mutated", admission.ReasonAlways)
+	recorder.RecordMutation("prod", web, "mutated", admission.ReasonAlways)
+	recorder.RecordMutation("default", web, "skipped", admission.ReasonOptedOut)
+	recorder.RecordMutation("default", web, "skipped", admission.ReasonAlreadyCompliant)
 
 	// Verify counts
-	assert.Equal(t, float64(2), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "mutated")))
-	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("prod", "mutated")))
-	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "skipped")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "mutated", "always")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("prod", "mutated", "always")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "skipped", "opted-out")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.mutationsTotal.WithLabelValues("default", "skipped", "already-compliant")))
+}
+
+func TestRecorder_RecordDecisionCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder := NewRecorder(reg)
+
+	recorder.RecordDecisionCache(admission.CacheMiss)
+	recorder.RecordDecisionCache(admission.CacheHit)
+	recorder.RecordDecisionCache(admission.CacheHit)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(recorder.decisionCache.WithLabelValues("hit")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(recorder.decisionCache.WithLabelValues("miss")))
 }
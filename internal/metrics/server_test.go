@@ -10,6 +10,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
 )
 
 func TestNewServer(t *testing.T) {
@@ -50,8 +52,8 @@ func TestServer_ServesMetrics(t *testing.T) {
 	recorder := NewRecorder(reg)
 
 	// Record some metrics
-	recorder.RecordMutation("default", "mutated")
-	recorder.RecordError("decode")
+	recorder.RecordMutation("default", web, "mutated", admission.ReasonAlways)
+	recorder.RecordError(admission.CodeDecodeReview)
 
 	// Use port 0 and parse the actual address from the listener
 	port := 18081 // Use a high port to avoid conflicts
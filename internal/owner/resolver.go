@@ -0,0 +1,129 @@
+// Package owner resolves the controlling workloads of pods from informer caches.
+package owner
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultResources are the owner kinds the Resolver follows unless
+// configured otherwise, with the resources it watches for them.
+var DefaultResources = map[schema.GroupVersionKind]schema.GroupVersionResource{
+	{Group: "apps", Version: "v1", Kind: "ReplicaSet"}:  {Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"}:  {Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"}: {Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"}:   {Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "batch", Version: "v1", Kind: "Job"}:        {Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "batch", Version: "v1", Kind: "CronJob"}:    {Group: "batch", Version: "v1", Resource: "cronjobs"},
+}
+
+// ParseResources parses owner kinds of the form group/version/Kind=resource,
+// such as "apps/v1/Deployment=deployments", or version/Kind=resource for the
+// core group.
+func ParseResources(specs []string) (map[schema.GroupVersionKind]schema.GroupVersionResource, error) {
+	resources := make(map[schema.GroupVersionKind]schema.GroupVersionResource, len(specs))
+	for _, spec := range specs {
+		kind, resource, ok := strings.Cut(spec, "=")
+		slash := strings.LastIndex(kind, "/")
+		if !ok || slash < 0 || resource == "" || slash == len(kind)-1 {
+			return nil, fmt.Errorf("owner resource %q is not of the form group/version/Kind=resource", spec)
+		}
+		gv, err := schema.ParseGroupVersion(kind[:slash])
+		if err != nil || gv.Version == "" {
+			return nil, fmt.Errorf("owner resource %q has an invalid group/version", spec)
+		}
+		resources[gv.WithKind(kind[slash+1:])] = gv.WithResource(resource)
+	}
+	return resources, nil
+}
+
+// Resolver walks controller owner references (ReplicaSet -> Deployment,
+// Job -> CronJob, StatefulSet -> an operator's custom resource, ...) using
+// metadata-only informers, so lookups never call the API server and the
+// cache only holds object metadata.
+type Resolver struct {
+	listers  map[schema.GroupVersionKind]cache.GenericLister
+	synced   []cache.InformerSynced
+	maxDepth int
+}
+
+// NewResolver registers informers for the owner kinds in resources with
+// factory. The factory must be started by the caller. maxDepth bounds the
+// number of owner references followed per lookup.
+func NewResolver(factory metadatainformer.SharedInformerFactory, resources map[schema.GroupVersionKind]schema.GroupVersionResource, maxDepth int) *Resolver {
+	r := &Resolver{
+		listers:  make(map[schema.GroupVersionKind]cache.GenericLister, len(resources)),
+		maxDepth: maxDepth,
+	}
+	for gvk, gvr := range resources {
+		informer := factory.ForResource(gvr)
+		r.listers[gvk] = informer.Lister()
+		r.synced = append(r.synced, informer.Informer().HasSynced)
+	}
+	return r
+}
+
+// HasSynced reports whether all owner caches have synced.
+func (r *Resolver) HasSynced() bool {
+	for _, synced := range r.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// ControllerChain returns the controlling owners of an object in namespace
+// with the given owner references, nearest first. The walk stops at unknown
+// kinds, missing or replaced owners, and after maxDepth hops. It returns nil
+// until the caches have synced.
+func (r *Resolver) ControllerChain(namespace string, refs []metav1.OwnerReference) []metav1.Object {
+	if !r.HasSynced() {
+		return nil
+	}
+
+	var chain []metav1.Object
+	for depth := 0; depth < r.maxDepth; depth++ {
+		ref := controllerRef(refs)
+		if ref == nil {
+			break
+		}
+
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			break
+		}
+		lister, ok := r.listers[gv.WithKind(ref.Kind)]
+		if !ok {
+			break
+		}
+
+		obj, err := lister.ByNamespace(namespace).Get(ref.Name)
+		if err != nil {
+			break
+		}
+		owner, err := meta.Accessor(obj)
+		if err != nil || owner.GetUID() != ref.UID {
+			break
+		}
+
+		chain = append(chain, owner)
+		refs = owner.GetOwnerReferences()
+	}
+	return chain
+}
+
+func controllerRef(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	return nil
+}
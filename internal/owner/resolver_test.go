@@ -0,0 +1,148 @@
+package owner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/metadata/metadatainformer"
+)
+
+func newObject(apiVersion, kind, name string, uid types.UID, owner *metav1.PartialObjectMetadata) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			UID:         uid,
+			Annotations: map[string]string{"kind": kind},
+		},
+	}
+	if owner != nil {
+		obj.OwnerReferences = []metav1.OwnerReference{ownerRef(owner)}
+	}
+	return obj
+}
+
+func ownerRef(obj *metav1.PartialObjectMetadata) metav1.OwnerReference {
+	controller := true
+	return metav1.OwnerReference{
+		APIVersion: obj.APIVersion,
+		Kind:       obj.Kind,
+		Name:       obj.Name,
+		UID:        obj.UID,
+		Controller: &controller,
+	}
+}
+
+func newTestResolver(t *testing.T, resources map[schema.GroupVersionKind]schema.GroupVersionResource, maxDepth int, objects ...runtime.Object) *Resolver {
+	t.Helper()
+
+	scheme := metadatafake.NewTestScheme()
+	require.NoError(t, metav1.AddMetaToScheme(scheme))
+	client := metadatafake.NewSimpleMetadataClient(scheme, objects...)
+
+	factory := metadatainformer.NewSharedInformerFactory(client, 0)
+	resolver := NewResolver(factory, resources, maxDepth)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	require.True(t, resolver.HasSynced())
+
+	return resolver
+}
+
+func kinds(chain []metav1.Object) []string {
+	var out []string
+	for _, obj := range chain {
+		out = append(out, obj.GetAnnotations()["kind"])
+	}
+	return out
+}
+
+func TestResolver_ControllerChain(t *testing.T) {
+	deploy := newObject("apps/v1", "Deployment", "web", "uid-deploy", nil)
+	rs := newObject("apps/v1", "ReplicaSet", "web-7d9f8", "uid-rs", deploy)
+	cronJob := newObject("batch/v1", "CronJob", "report", "uid-cron", nil)
+	job := newObject("batch/v1", "Job", "report-2891", "uid-job", cronJob)
+
+	resolver := newTestResolver(t, DefaultResources, 2, deploy, rs, cronJob, job)
+
+	t.Run("replicaset to deployment", func(t *testing.T) {
+		chain := resolver.ControllerChain("default", []metav1.OwnerReference{ownerRef(rs)})
+		assert.Equal(t, []string{"ReplicaSet", "Deployment"}, kinds(chain))
+	})
+
+	t.Run("job to cronjob", func(t *testing.T) {
+		chain := resolver.ControllerChain("default", []metav1.OwnerReference{ownerRef(job)})
+		assert.Equal(t, []string{"Job", "CronJob"}, kinds(chain))
+	})
+
+	t.Run("no controller reference", func(t *testing.T) {
+		ref := ownerRef(rs)
+		ref.Controller = nil
+		assert.Empty(t, resolver.ControllerChain("default", []metav1.OwnerReference{ref}))
+	})
+
+	t.Run("stale uid", func(t *testing.T) {
+		ref := ownerRef(rs)
+		ref.UID = "replaced"
+		assert.Empty(t, resolver.ControllerChain("default", []metav1.OwnerReference{ref}))
+	})
+
+	t.Run("other namespace", func(t *testing.T) {
+		assert.Empty(t, resolver.ControllerChain("prod", []metav1.OwnerReference{ownerRef(rs)}))
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		custom := newObject("example.com/v1", "Database", "db", "uid-db", nil)
+		assert.Empty(t, resolver.ControllerChain("default", []metav1.OwnerReference{ownerRef(custom)}))
+	})
+}
+
+func TestResolver_ControllerChain_MaxDepth(t *testing.T) {
+	deploy := newObject("apps/v1", "Deployment", "web", "uid-deploy", nil)
+	rs := newObject("apps/v1", "ReplicaSet", "web-7d9f8", "uid-rs", deploy)
+
+	resolver := newTestResolver(t, DefaultResources, 1, deploy, rs)
+
+	chain := resolver.ControllerChain("default", []metav1.OwnerReference{ownerRef(rs)})
+	assert.Equal(t, []string{"ReplicaSet"}, kinds(chain))
+}
+
+func TestResolver_ControllerChain_CustomKind(t *testing.T) {
+	resources, err := ParseResources([]string{
+		"apps/v1/StatefulSet=statefulsets",
+		"db.example.com/v1alpha1/Database=databases",
+	})
+	require.NoError(t, err)
+
+	database := newObject("db.example.com/v1alpha1", "Database", "orders", "uid-db", nil)
+	sts := newObject("apps/v1", "StatefulSet", "orders-db", "uid-sts", database)
+	resolver := newTestResolver(t, resources, 2, database, sts)
+
+	chain := resolver.ControllerChain("default", []metav1.OwnerReference{ownerRef(sts)})
+	assert.Equal(t, []string{"StatefulSet", "Database"}, kinds(chain))
+}
+
+func TestParseResources(t *testing.T) {
+	resources, err := ParseResources([]string{"apps/v1/Deployment=deployments", "v1/ReplicationController=replicationcontrollers"})
+	require.NoError(t, err)
+	assert.Equal(t, map[schema.GroupVersionKind]schema.GroupVersionResource{
+		{Group: "apps", Version: "v1", Kind: "Deployment"}: {Group: "apps", Version: "v1", Resource: "deployments"},
+		{Version: "v1", Kind: "ReplicationController"}:     {Version: "v1", Resource: "replicationcontrollers"},
+	}, resources)
+
+	for _, spec := range []string{"apps/v1/Deployment", "Deployment=deployments", "apps/v1/=deployments", "a/b/c/Kind=kinds"} {
+		_, err := ParseResources([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
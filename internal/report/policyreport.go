@@ -0,0 +1,100 @@
+// Package report maintains wgpolicyk8s.io PolicyReports describing how
+// running pods comply with the ndots policy.
+package report
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+)
+
+// GVR is the PolicyReport resource written by the Reporter.
+var GVR = schema.GroupVersionResource{Group: "wgpolicyk8s.io", Version: "v1alpha2", Resource: "policyreports"}
+
+// Names used in the PolicyReports. Each namespace with pods gets one report
+// called ReportName.
+const (
+	ReportName = "ndots-webhook"
+	Source     = "ndots-webhook"
+	PolicyName = "ndots"
+	RuleName   = "ndots-value"
+)
+
+// PolicyReport result values.
+const (
+	ResultPass = "pass"
+	ResultFail = "fail"
+	ResultSkip = "skip"
+)
+
+// Checker classifies pods against the ndots policy. It is implemented by
+// admission.Mutator.
+type Checker interface {
+	Check(pod *corev1.Pod) admission.ComplianceResult
+}
+
+// buildReport returns the PolicyReport for the pods of namespace, or nil if
+// there are no pods to report on.
+func buildReport(namespace string, pods []*corev1.Pod, checker Checker) *unstructured.Unstructured {
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	summary := map[string]interface{}{
+		"pass": int64(0), "fail": int64(0), "warn": int64(0), "error": int64(0), "skip": int64(0),
+	}
+	var results []interface{}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		result, message := podResult(checker.Check(pod))
+		summary[result] = summary[result].(int64) + 1
+		results = append(results, map[string]interface{}{
+			"policy":  PolicyName,
+			"rule":    RuleName,
+			"result":  result,
+			"scored":  true,
+			"source":  Source,
+			"message": message,
+			"properties": map[string]interface{}{
+				"workload": admission.WorkloadOf(pod).String(),
+			},
+			"resources": []interface{}{map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"namespace":  namespace,
+				"name":       pod.Name,
+				"uid":        string(pod.UID),
+			}},
+		})
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	report := &unstructured.Unstructured{Object: map[string]interface{}{
+		"summary": summary,
+		"results": results,
+	}}
+	report.SetAPIVersion(GVR.GroupVersion().String())
+	report.SetKind("PolicyReport")
+	report.SetNamespace(namespace)
+	report.SetName(ReportName)
+	report.SetLabels(map[string]string{"app.kubernetes.io/managed-by": Source})
+	return report
+}
+
+func podResult(c admission.ComplianceResult) (string, string) {
+	switch c.Compliance {
+	case admission.Compliant:
+		return ResultPass, fmt.Sprintf("ndots is %d", c.Ndots)
+	case admission.NonCompliant:
+		return ResultFail, fmt.Sprintf("ndots is %d, policy requires %d", c.Ndots, c.Want)
+	default:
+		return ResultSkip, fmt.Sprintf("%s by %s", c.Compliance, c.Reason)
+	}
+}
@@ -0,0 +1,166 @@
+package report
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Reporter keeps one PolicyReport per namespace in line with the pods in a
+// pod informer cache. Pod changes queue their namespace; a single worker
+// rebuilds the namespace's report and writes it if it changed, at most
+// writesPerSecond times per second.
+type Reporter struct {
+	pods    corelisters.PodLister
+	synced  cache.InformerSynced
+	client  dynamic.Interface
+	checker Checker
+	queue   workqueue.TypedRateLimitingInterface[string]
+	limiter *rate.Limiter
+	resync  time.Duration
+	logger  *slog.Logger
+}
+
+// NewReporter registers a pod event handler with factory. The factory must
+// be started by the caller. Every resync all namespaces are re-evaluated, so
+// policy changes that no pod event reflects, such as tenant policies, show
+// up; zero disables this.
+func NewReporter(factory informers.SharedInformerFactory, client dynamic.Interface, checker Checker, writesPerSecond int, resync time.Duration, logger *slog.Logger) (*Reporter, error) {
+	informer := factory.Core().V1().Pods()
+	r := &Reporter{
+		pods:    informer.Lister(),
+		client:  client,
+		checker: checker,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "policyreports"},
+		),
+		limiter: rate.NewLimiter(rate.Limit(writesPerSecond), writesPerSecond),
+		resync:  resync,
+		logger:  logger,
+	}
+
+	if _, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    r.enqueue,
+		UpdateFunc: func(_, obj interface{}) { r.enqueue(obj) },
+		DeleteFunc: r.enqueue,
+	}); err != nil {
+		return nil, err
+	}
+	r.synced = informer.Informer().HasSynced
+
+	return r, nil
+}
+
+// Run writes reports until ctx is done. It blocks.
+func (r *Reporter) Run(ctx context.Context) {
+	defer r.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), r.synced) {
+		return
+	}
+	go func() {
+		for r.processNext(ctx) {
+		}
+	}()
+	if r.resync > 0 {
+		go wait.Until(r.enqueueAll, r.resync, ctx.Done())
+	}
+	<-ctx.Done()
+}
+
+// enqueueAll queues every namespace with pods.
+func (r *Reporter) enqueueAll() {
+	pods, err := r.pods.List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, pod := range pods {
+		r.queue.Add(pod.Namespace)
+	}
+}
+
+func (r *Reporter) enqueue(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if pod, ok := obj.(*corev1.Pod); ok {
+		r.queue.Add(pod.Namespace)
+	}
+}
+
+func (r *Reporter) processNext(ctx context.Context) bool {
+	namespace, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(namespace)
+
+	if err := r.sync(ctx, namespace); err != nil {
+		r.logger.Warn("failed to write policy report", "namespace", namespace, "error", err)
+		r.queue.AddRateLimited(namespace)
+		return true
+	}
+	r.queue.Forget(namespace)
+	return true
+}
+
+// sync creates, updates or deletes the report of namespace.
+func (r *Reporter) sync(ctx context.Context, namespace string) error {
+	pods, err := r.pods.Pods(namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	desired := buildReport(namespace, pods, r.checker)
+
+	reports := r.client.Resource(GVR).Namespace(namespace)
+	existing, err := reports.Get(ctx, ReportName, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		if desired == nil {
+			return nil
+		}
+		if err := r.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		_, err = reports.Create(ctx, desired, metav1.CreateOptions{})
+		return err
+	case err != nil:
+		return err
+	}
+
+	if desired == nil {
+		if err := r.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		err := reports.Delete(ctx, ReportName, metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(existing.Object["results"], desired.Object["results"]) &&
+		equality.Semantic.DeepEqual(existing.Object["summary"], desired.Object["summary"]) {
+		return nil
+	}
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	if err := r.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	_, err = reports.Update(ctx, desired, metav1.UpdateOptions{})
+	return err
+}
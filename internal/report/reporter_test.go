@@ -0,0 +1,116 @@
+package report
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/config"
+)
+
+func newPod(namespace, name string, annotations map[string]string, ndots string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   namespace,
+		Name:        name,
+		UID:         types.UID("uid-" + name),
+		Annotations: annotations,
+	}}
+	if ndots != "" {
+		pod.Spec.DNSConfig = &corev1.PodDNSConfig{
+			Options: []corev1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+		}
+	}
+	return pod
+}
+
+func testChecker() Checker {
+	return admission.NewMutator(&config.Config{
+		NdotsValue:     2,
+		AnnotationKey:  "change-ndots",
+		AnnotationMode: "opt-out",
+	}, slog.Default())
+}
+
+func TestBuildReport(t *testing.T) {
+	pods := []*corev1.Pod{
+		newPod("shop", "web", nil, "2"),
+		newPod("shop", "api", nil, ""),
+		newPod("shop", "legacy", map[string]string{"change-ndots": "false"}, ""),
+	}
+	done := newPod("shop", "migrate", nil, "")
+	done.Status.Phase = corev1.PodSucceeded
+	pods = append(pods, done)
+
+	report := buildReport("shop", pods, testChecker())
+	require.NotNil(t, report)
+	assert.Equal(t, "PolicyReport", report.GetKind())
+	assert.Equal(t, ReportName, report.GetName())
+
+	summary, _, _ := unstructured.NestedMap(report.Object, "summary")
+	assert.Equal(t, map[string]interface{}{
+		"pass": int64(1), "fail": int64(1), "warn": int64(0), "error": int64(0), "skip": int64(1),
+	}, summary)
+
+	results, _, _ := unstructured.NestedSlice(report.Object, "results")
+	require.Len(t, results, 3)
+	var messages []string
+	for _, r := range results {
+		messages = append(messages, r.(map[string]interface{})["message"].(string))
+	}
+	assert.Equal(t, []string{"ndots is 5, policy requires 2", "skipped by annotation", "ndots is 2"}, messages)
+
+	assert.Nil(t, buildReport("shop", []*corev1.Pod{done}, testChecker()))
+}
+
+func TestReporter(t *testing.T) {
+	client := fake.NewClientset(
+		newPod("shop", "web", nil, "2"),
+		newPod("shop", "api", nil, ""),
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GVR: "PolicyReportList"})
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	reporter, err := NewReporter(factory, dynamicClient, testChecker(), 100, 0, slog.Default())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	go reporter.Run(ctx)
+
+	reports := dynamicClient.Resource(GVR).Namespace("shop")
+	fail := func() int64 {
+		report, err := reports.Get(ctx, ReportName, metav1.GetOptions{})
+		if err != nil {
+			return -1
+		}
+		n, _, _ := unstructured.NestedInt64(report.Object, "summary", "fail")
+		return n
+	}
+	require.Eventually(t, func() bool { return fail() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.CoreV1().Pods("shop").Delete(ctx, "api", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool { return fail() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.CoreV1().Pods("shop").Delete(ctx, "web", metav1.DeleteOptions{}))
+	require.Eventually(t, func() bool {
+		_, err := reports.Get(ctx, ReportName, metav1.GetOptions{})
+		return apierrors.IsNotFound(err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -71,3 +71,30 @@ func TestServer_HealthEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func TestRequireBearerToken(t *testing.T) {
+	handler := RequireBearerToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := map[string]struct {
+		header string
+		want   int
+	}{
+		"valid token":   {header: "Bearer s3cret", want: http.StatusOK},
+		"wrong token":   {header: "Bearer guess", want: http.StatusUnauthorized},
+		"missing token": {header: "", want: http.StatusUnauthorized},
+		"basic auth":    {header: "Basic czNjcmV0", want: http.StatusUnauthorized},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/debug/decisions", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package tenant reads per-namespace ndots policies from well-known ConfigMaps.
+package tenant
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+)
+
+// ConfigMap data keys of a tenant policy.
+const (
+	KeyMode           = "mode"
+	KeyNdots          = "ndots"
+	KeyExemptSelector = "exemptSelector"
+)
+
+// Limits bound what tenants may configure in their namespace.
+type Limits struct {
+	AllowedModes []string
+	NdotsMin     int
+	NdotsMax     int
+}
+
+// ParsePolicy validates the data of a tenant policy ConfigMap against limits.
+func ParsePolicy(cm *corev1.ConfigMap, limits Limits) (*admission.NamespacePolicy, error) {
+	policy := &admission.NamespacePolicy{}
+
+	for key, value := range cm.Data {
+		value = strings.TrimSpace(value)
+		switch key {
+		case KeyMode:
+			mode := strings.ToLower(value)
+			if !slices.Contains(limits.AllowedModes, mode) {
+				return nil, fmt.Errorf("%s %q is not allowed, must be one of %s", KeyMode, value, strings.Join(limits.AllowedModes, ", "))
+			}
+			policy.AnnotationMode = mode
+		case KeyNdots:
+			ndots, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s %q is not a number", KeyNdots, value)
+			}
+			if ndots < limits.NdotsMin || ndots > limits.NdotsMax {
+				return nil, fmt.Errorf("%s %d is outside the allowed range %d-%d", KeyNdots, ndots, limits.NdotsMin, limits.NdotsMax)
+			}
+			policy.NdotsValue = &ndots
+		case KeyExemptSelector:
+			selector, err := labels.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a valid label selector: %w", KeyExemptSelector, err)
+			}
+			if !selector.Empty() {
+				policy.Exempt = selector
+			}
+		default:
+			return nil, fmt.Errorf("unknown key %q, supported keys are %s, %s and %s", key, KeyMode, KeyNdots, KeyExemptSelector)
+		}
+	}
+
+	return policy, nil
+}
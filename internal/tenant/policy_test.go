@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+var testLimits = Limits{
+	AllowedModes: []string{"opt-in", "opt-out"},
+	NdotsMin:     1,
+	NdotsMax:     3,
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      map[string]string
+		wantMode  string
+		wantNdots *int
+		wantErr   string
+	}{
+		{name: "empty", data: nil},
+		{name: "mode only", data: map[string]string{"mode": "Opt-In"}, wantMode: "opt-in"},
+		{name: "ndots only", data: map[string]string{"ndots": " 3 "}, wantNdots: intPtr(3)},
+		{name: "mode not allowed", data: map[string]string{"mode": "always"}, wantErr: "mode \"always\" is not allowed"},
+		{name: "ndots not a number", data: map[string]string{"ndots": "two"}, wantErr: "not a number"},
+		{name: "ndots above limit", data: map[string]string{"ndots": "5"}, wantErr: "outside the allowed range 1-3"},
+		{name: "ndots below limit", data: map[string]string{"ndots": "0"}, wantErr: "outside the allowed range 1-3"},
+		{name: "invalid selector", data: map[string]string{"exemptSelector": "app in (a"}, wantErr: "not a valid label selector"},
+		{name: "unknown key", data: map[string]string{"ndot": "2"}, wantErr: "unknown key \"ndot\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePolicy(&corev1.ConfigMap{Data: tt.data}, testLimits)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMode, policy.AnnotationMode)
+			assert.Equal(t, tt.wantNdots, policy.NdotsValue)
+			assert.Nil(t, policy.Exempt)
+		})
+	}
+}
+
+func TestParsePolicy_ExemptSelector(t *testing.T) {
+	policy, err := ParsePolicy(&corev1.ConfigMap{Data: map[string]string{
+		"exemptSelector": "app in (legacy-dns, resolver)",
+	}}, testLimits)
+	require.NoError(t, err)
+	require.NotNil(t, policy.Exempt)
+
+	assert.True(t, policy.Exempt.Matches(labels.Set{"app": "legacy-dns"}))
+	assert.False(t, policy.Exempt.Matches(labels.Set{"app": "web"}))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
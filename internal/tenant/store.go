@@ -0,0 +1,130 @@
+package tenant
+
+import (
+	"log/slog"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/hawky-4s-/k8s-ndots-admission-controller/internal/admission"
+)
+
+// ReasonInvalidPolicy is the event reason for rejected tenant ConfigMaps.
+const ReasonInvalidPolicy = "InvalidNdotsPolicy"
+
+// Store keeps the valid tenant policies of all namespaces, fed by a ConfigMap
+// informer. It implements admission.PolicySource.
+type Store struct {
+	name     string
+	limits   Limits
+	recorder record.EventRecorder
+	logger   *slog.Logger
+	synced   cache.InformerSynced
+
+	mu       sync.RWMutex
+	policies map[string]*admission.NamespacePolicy
+	onChange func()
+}
+
+// NewStore registers a ConfigMap event handler with factory that tracks
+// ConfigMaps called name. Invalid ConfigMaps are ignored and a warning event
+// explaining why is recorded on them. The factory must be started by the
+// caller; it should be filtered to name to keep the cache small.
+func NewStore(factory informers.SharedInformerFactory, name string, limits Limits, recorder record.EventRecorder, logger *slog.Logger) (*Store, error) {
+	s := &Store{
+		name:     name,
+		limits:   limits,
+		recorder: recorder,
+		logger:   logger,
+		policies: make(map[string]*admission.NamespacePolicy),
+	}
+
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.upsert,
+		UpdateFunc: func(_, obj interface{}) { s.upsert(obj) },
+		DeleteFunc: s.delete,
+	}); err != nil {
+		return nil, err
+	}
+	s.synced = informer.HasSynced
+
+	return s, nil
+}
+
+// HasSynced reports whether the ConfigMap cache has synced.
+func (s *Store) HasSynced() bool {
+	return s.synced()
+}
+
+// OnChange calls fn after a namespace's policy is added, updated or removed,
+// e.g. to invalidate decisions made with the old policy.
+func (s *Store) OnChange(fn func()) {
+	s.mu.Lock()
+	s.onChange = fn
+	s.mu.Unlock()
+}
+
+// NamespacePolicy returns the valid tenant policy of namespace, if any.
+func (s *Store) NamespacePolicy(namespace string) (*admission.NamespacePolicy, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	policy, ok := s.policies[namespace]
+	return policy, ok
+}
+
+func (s *Store) upsert(obj interface{}) {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.Name != s.name {
+		return
+	}
+
+	policy, err := ParsePolicy(cm, s.limits)
+	if err != nil {
+		s.logger.Warn("ignoring invalid tenant policy",
+			"namespace", cm.Namespace,
+			"name", cm.Name,
+			"error", err,
+		)
+		s.recorder.Eventf(cm, corev1.EventTypeWarning, ReasonInvalidPolicy, "ndots policy ignored: %v", err)
+		s.remove(cm.Namespace)
+		return
+	}
+
+	s.logger.Info("applied tenant policy", "namespace", cm.Namespace, "name", cm.Name)
+	s.mu.Lock()
+	s.policies[cm.Namespace] = policy
+	s.mu.Unlock()
+	s.changed()
+}
+
+func (s *Store) delete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok || cm.Name != s.name {
+		return
+	}
+	s.logger.Info("removed tenant policy", "namespace", cm.Namespace, "name", cm.Name)
+	s.remove(cm.Namespace)
+}
+
+func (s *Store) remove(namespace string) {
+	s.mu.Lock()
+	delete(s.policies, namespace)
+	s.mu.Unlock()
+	s.changed()
+}
+
+func (s *Store) changed() {
+	s.mu.RLock()
+	fn := s.onChange
+	s.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
@@ -0,0 +1,99 @@
+package tenant
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newConfigMap(namespace, name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data:       data,
+	}
+}
+
+func TestStore(t *testing.T) {
+	client := fake.NewClientset(
+		newConfigMap("team-a", "ndots-policy", map[string]string{"ndots": "3"}),
+		newConfigMap("team-b", "ndots-policy", map[string]string{"ndots": "9"}),
+		newConfigMap("team-c", "other-config", map[string]string{"ndots": "1"}),
+	)
+	recorder := record.NewFakeRecorder(10)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	store, err := NewStore(factory, "ndots-policy", testLimits, recorder, slog.Default())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	require.True(t, store.HasSynced())
+
+	policy, ok := store.NamespacePolicy("team-a")
+	require.True(t, ok)
+	assert.Equal(t, 3, *policy.NdotsValue)
+
+	_, ok = store.NamespacePolicy("team-b")
+	assert.False(t, ok, "invalid policy must be ignored")
+	select {
+	case event := <-recorder.Events:
+		assert.Contains(t, event, "Warning "+ReasonInvalidPolicy)
+		assert.Contains(t, event, "outside the allowed range")
+	case <-time.After(time.Second):
+		t.Fatal("expected event for invalid policy")
+	}
+
+	_, ok = store.NamespacePolicy("team-c")
+	assert.False(t, ok, "other ConfigMaps must be ignored")
+
+	t.Run("update to invalid removes policy", func(t *testing.T) {
+		_, err := client.CoreV1().ConfigMaps("team-a").Update(ctx,
+			newConfigMap("team-a", "ndots-policy", map[string]string{"mode": "always"}), metav1.UpdateOptions{})
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			_, ok := store.NamespacePolicy("team-a")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("delete removes policy", func(t *testing.T) {
+		_, err := client.CoreV1().ConfigMaps("team-d").Create(ctx,
+			newConfigMap("team-d", "ndots-policy", map[string]string{"mode": "opt-in"}), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			_, ok := store.NamespacePolicy("team-d")
+			return ok
+		}, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, client.CoreV1().ConfigMaps("team-d").Delete(ctx, "ndots-policy", metav1.DeleteOptions{}))
+		assert.Eventually(t, func() bool {
+			_, ok := store.NamespacePolicy("team-d")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("changes are reported", func(t *testing.T) {
+		var changes atomic.Int32
+		store.OnChange(func() { changes.Add(1) })
+
+		_, err := client.CoreV1().ConfigMaps("team-e").Create(ctx,
+			newConfigMap("team-e", "ndots-policy", map[string]string{"ndots": "2"}), metav1.CreateOptions{})
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return changes.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, client.CoreV1().ConfigMaps("team-e").Delete(ctx, "ndots-policy", metav1.DeleteOptions{}))
+		assert.Eventually(t, func() bool { return changes.Load() == 2 }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -827,49 +827,14 @@ func TestIntegration_NamespaceMutationAcrossScenarios(t *testing.T) {
 // TestIntegration_HandlerNamespaceResolution tests the interaction between the handler's
 // namespace resolution (req.Namespace vs pod.Namespace) and the mutator's namespace filtering.
 //
-// Scenario:
-//
-//	In real K8s, pod.metadata.namespace is often EMPTY during admission.
-//	The namespace lives in AdmissionRequest.namespace instead.
-//
-//	┌──────────────────────────┐    ┌────────────────────────────────┐
-//	│  AdmissionRequest        │    │  Pod Object                    │
-//	│  req.Namespace = ???     │    │  pod.Namespace = ???           │
-//	└────────────┬─────────────┘    └───────────────┬────────────────┘
-//	             │                                  │
-//	             └──────────┬───────────────────────┘
-//	                        ▼
-//	            ┌───────────────────────┐
-//	            │   Handler resolves NS │
-//	            │   from req.Namespace  │
-//	            └───────────┬───────────┘
-//	                        │
-//	                        ▼          ⚠️  BUG: resolved NS not
-//	              ┌─────────────────┐      passed to mutator!
-//	              │     Mutator     │      mutator sees pod.NS = ""
-//	              └────────┬────────┘
-//	                       │
-//	 ┌─────────────────────┼─────────────────────────────┐
-//	 │                     │                             │
-//	 ▼                     ▼                             ▼
+// In real Kubernetes admission webhooks, the pod object's Namespace field is often empty
+// during admission; the namespace lives on the AdmissionRequest instead. The handler passes
+// req.Namespace to the mutator, falling back to pod.Namespace only when the request carries
+// none, so the exclude list must apply to pods whose own Namespace is empty:
 //
 //	pod.NS="default"     pod.NS=""                  pod.NS=""
 //	req.NS="default"     req.NS="default"           req.NS="kube-system"
-//	✅ mutated           ✅ mutated                 🐛 MUTATED (BUG!)
-//	(both agree)         (handler resolves ok)       should be excluded
-//	                                                 but "" ≠ "kube-system"
-//	                                                 so exclude list misses it
-//
-// BUG CONTEXT: In real Kubernetes admission webhooks, the pod object's Namespace field is
-// often empty during admission (Kubernetes sets it on the AdmissionRequest, not on the pod).
-// The handler resolves namespace correctly from req.Namespace (handler.go lines 138-141),
-// but the resolved value is only used for logging/metrics. The pod struct is passed directly
-// to mutator.Mutate() without setting pod.Namespace, so the mutator's namespace filter
-// (mutator.go line 31) reads pod.Namespace which may be empty.
-//
-// When pod.Namespace is empty and the request targets an excluded namespace (e.g. kube-system),
-// the empty string won't match the exclude list, causing the mutator to incorrectly mutate
-// pods that should be excluded.
+//	✅ mutated           ✅ mutated                 ✅ excluded
 func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 	// Full-stack setup with exact bug report config values
 	cfg := &config.Config{
@@ -896,10 +861,7 @@ func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 		pod  corev1.Pod
 		// reqNamespace is the namespace on the AdmissionRequest (set by Kubernetes API server)
 		reqNamespace string
-		// wantMutated is the EXPECTED correct behavior: should the pod be mutated?
-		// NOTE: Some tests may FAIL if the bug is present, which is the intended
-		// diagnostic outcome. The wantMutated value reflects the CORRECT behavior
-		// (i.e., what SHOULD happen), not necessarily what currently happens.
+		// wantMutated is whether the pod should be mutated.
 		wantMutated bool
 		wantNdots   string
 	}{
@@ -938,19 +900,15 @@ func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 			wantNdots:    "2",
 		},
 		{
-			// KEY BUG VECTOR: pod.Namespace is empty, req.Namespace is 'kube-system' (excluded).
-			// The handler resolves namespace to 'kube-system' from req.Namespace, but only
-			// uses it for logging. The mutator reads pod.Namespace which is "" (empty).
-			// Empty string is NOT in the exclude list ["kube-system", "kube-public", "kube-node-lease"],
-			// so the namespace filter returns true (should mutate) when it should return false.
-			//
-			// EXPECTED (correct behavior): NOT mutated (kube-system is excluded)
-			// ACTUAL (with bug): mutated (empty string bypasses exclusion check)
-			name: "empty_pod_namespace_req_kube_system_should_NOT_mutate_but_bug_may_allow_it",
+			// pod.Namespace is empty, req.Namespace is 'kube-system' (excluded).
+			// The mutator must filter on req.Namespace; the empty pod.Namespace is
+			// not in the exclude list and would let the pod through.
+			// Expected: NOT mutated (kube-system is excluded)
+			name: "empty_pod_namespace_req_kube_system_should_NOT_mutate",
 			pod: corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test-pod-kube-system",
-					// Namespace intentionally left empty - this is the bug trigger
+					// Namespace intentionally left empty to simulate real K8s behavior
 				},
 				Spec: corev1.PodSpec{},
 			},
@@ -958,16 +916,14 @@ func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 			wantMutated:  false,
 		},
 		{
-			// Same bug vector as kube-system but for kube-public.
+			// Same as kube-system but for kube-public.
 			// pod.Namespace is empty, req.Namespace is 'kube-public' (excluded).
-			//
-			// EXPECTED (correct behavior): NOT mutated (kube-public is excluded)
-			// ACTUAL (with bug): mutated (empty string bypasses exclusion check)
-			name: "empty_pod_namespace_req_kube_public_should_NOT_mutate_but_bug_may_allow_it",
+			// Expected: NOT mutated (kube-public is excluded)
+			name: "empty_pod_namespace_req_kube_public_should_NOT_mutate",
 			pod: corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: "test-pod-kube-public",
-					// Namespace intentionally left empty - this is the bug trigger
+					// Namespace intentionally left empty to simulate real K8s behavior
 				},
 				Spec: corev1.PodSpec{},
 			},
@@ -978,8 +934,6 @@ func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 			// Non-excluded namespace with empty pod.Namespace.
 			// pod.Namespace is empty, req.Namespace is 'my-app' (not excluded).
 			// Expected: mutated (my-app is not in the exclude list)
-			// This should work correctly even with the bug, since 'my-app' is not
-			// excluded and empty string is also not excluded.
 			name: "empty_pod_namespace_req_my_app_should_mutate",
 			pod: corev1.Pod{
 				ObjectMeta: metav1.ObjectMeta{
@@ -1074,8 +1028,7 @@ func TestIntegration_HandlerNamespaceResolution(t *testing.T) {
 			} else {
 				assert.Empty(t, responseReview.Response.Patch,
 					"Expected pod to NOT be mutated (pod.Namespace=%q, req.Namespace=%q). "+
-						"If this fails, it indicates the bug where empty pod.Namespace bypasses "+
-						"namespace exclusion for req.Namespace=%q",
+						"An empty pod.Namespace must not bypass namespace exclusion for req.Namespace=%q",
 					tt.pod.Namespace, tt.reqNamespace, tt.reqNamespace)
 			}
 		})